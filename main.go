@@ -21,13 +21,17 @@ package main
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/go-yaml/yaml"
@@ -38,6 +42,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
+	"github.com/docker/docker/pkg/term"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/grammarly/rocker/src/dockerclient"
 	"github.com/grammarly/rocker/src/rocker/debugtrap"
@@ -65,6 +70,14 @@ func init() {
 }
 
 func main() {
+	if err := newApp().Run(os.Args); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+// newApp makes the command line application with all commands and their flags
+func newApp() *cli.App {
 	app := cli.NewApp()
 
 	app.Name = "rocker-compose"
@@ -75,10 +88,10 @@ func main() {
 		{"Stas Levental", "stas.levental@grammarly.com"},
 	}
 
-	fileArg := cli.StringFlag{
+	fileArg := cli.StringSliceFlag{
 		Name:  "file, f",
-		Value: "compose.yml",
-		Usage: "Path to configuration file which should be run, if `-` is given as a value, then STDIN will be used",
+		Value: &cli.StringSlice{},
+		Usage: "Path to configuration file which should be run, if `-` is given as a value, then STDIN will be used. Can pass multiple of this, files are merged in order (default: compose.yml)",
 	}
 
 	varsFlags := []cli.Flag{
@@ -131,6 +144,11 @@ func main() {
 		cli.BoolTFlag{
 			Name: "colors",
 		},
+		cli.StringFlag{
+			Name:  "history-dir",
+			Value: "",
+			Usage: "Directory to keep the history of applied manifests in (default ~/.rocker-compose/history)",
+		},
 		cli.DurationFlag{
 			Name:  "docker-ping-timeout",
 			Value: 2 * time.Second,
@@ -146,7 +164,7 @@ func main() {
 	app.Commands = []cli.Command{
 		{
 			Name:   "run",
-			Usage:  "execute manifest",
+			Usage:  "execute manifest, or only given containers with their dependencies: run [containers...]",
 			Action: runCommand,
 			Flags: append([]cli.Flag{
 				cli.BoolFlag{
@@ -166,12 +184,76 @@ func main() {
 					Value: 1 * time.Second,
 					Usage: "Wait and check exit codes of launched containers",
 				},
+				cli.BoolFlag{
+					Name:  "blue-green",
+					Usage: "Start changed containers next to the old ones and remove the old ones only when new are up",
+				},
+				cli.BoolFlag{
+					Name:  "rollback-on-failure",
+					Usage: "Restore removed containers from their previous specs if execution fails",
+				},
+				cli.BoolFlag{
+					Name:  "prune-volumes",
+					Usage: "Remove volumes of the namespace that are not in the manifest anymore",
+				},
 				cli.BoolFlag{
 					Name:  "ansible",
 					Usage: "output json in ansible format for easy parsing",
 				},
 			}, composeFlags...),
 		},
+		{
+			Name:   "plan",
+			Usage:  "print changes that 'run' would make, field by field: plan [containers...]",
+			Action: planCommand,
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "pull",
+					Usage: "Do pull images before planning",
+				},
+				cli.BoolFlag{
+					Name:  "blue-green",
+					Usage: "Plan blue/green replacement of changed containers, same as for 'run'",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Value: "text",
+					Usage: "Output format: text|json",
+				},
+				cli.StringFlag{
+					Name:  "out, O",
+					Usage: "Save the plan in JSON format to a file, which can be executed later with 'apply --plan'",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "apply",
+			Usage:  "execute the plan previously saved by 'plan --out'",
+			Action: applyCommand,
+			Flags: appendFlags(fileArg, varsFlags, []cli.Flag{
+				cli.StringFlag{
+					Name:  "plan, p",
+					Usage: "Path to the saved plan file, if `-` is given as a value, then STDIN will be used",
+				},
+				cli.BoolFlag{
+					Name:  "dry, d",
+					Usage: "Don't execute any run/stop operations on target docker",
+				},
+				cli.DurationFlag{
+					Name:  "wait",
+					Value: 1 * time.Second,
+					Usage: "Wait and check exit codes of launched containers",
+				},
+				cli.BoolFlag{
+					Name:  "rollback-on-failure",
+					Usage: "Restore removed containers from their previous specs if execution fails",
+				},
+				cli.BoolFlag{
+					Name:  "ansible",
+					Usage: "output json in ansible format for easy parsing",
+				},
+			}),
+		},
 		{
 			Name:   "pull",
 			Usage:  "pull images specified in the manifest",
@@ -187,7 +269,12 @@ func main() {
 			Name:   "rm",
 			Usage:  "stop and remove any containers specified in the manifest",
 			Action: rmCommand,
-			Flags:  composeFlags,
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "prune-volumes",
+					Usage: "Remove volumes of the namespace as well",
+				},
+			}, composeFlags...),
 		},
 		{
 			Name:   "clean",
@@ -226,7 +313,7 @@ func main() {
 			Name:   "recover",
 			Usage:  "recover containers from machine reboot or docker daemon restart",
 			Action: recoverCommand,
-			Flags: []cli.Flag{
+			Flags: appendFlags(fileArg, varsFlags, []cli.Flag{
 				cli.BoolFlag{
 					Name:  "dry, d",
 					Usage: "Don't execute any run/stop operations on target docker",
@@ -236,8 +323,200 @@ func main() {
 					Value: 1 * time.Second,
 					Usage: "Wait and check exit codes of launched containers",
 				},
+			}),
+		},
+		{
+			Name:   "history",
+			Usage:  "list revisions of the namespace applied by 'run' and 'apply'",
+			Action: historyCommand,
+			Flags:  appendFlags(fileArg, varsFlags),
+		},
+		{
+			Name:   "rollback",
+			Usage:  "run the previous (or given) revision of the namespace again",
+			Action: rollbackCommand,
+			Flags: append([]cli.Flag{
+				cli.IntFlag{
+					Name:  "to",
+					Usage: "Revision number to roll back to, the previous one by default",
+				},
+				cli.DurationFlag{
+					Name:  "wait",
+					Value: 1 * time.Second,
+					Usage: "Wait and check exit codes of launched containers",
+				},
+				cli.BoolFlag{
+					Name:  "blue-green",
+					Usage: "Start changed containers next to the old ones and remove the old ones only when new are up",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "start",
+			Usage:  "start stopped containers of the manifest, dependencies first: start [containers...]",
+			Action: lifecycleCommand((*compose.Compose).StartAction),
+			Flags: append([]cli.Flag{
+				cli.DurationFlag{
+					Name:  "wait",
+					Value: 1 * time.Second,
+					Usage: "Wait and check exit codes of started containers",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "stop",
+			Usage:  "stop running containers of the manifest, dependent ones first: stop [containers...]",
+			Action: lifecycleCommand((*compose.Compose).StopAction),
+			Flags:  composeFlags,
+		},
+		{
+			Name:   "restart",
+			Usage:  "stop and start containers of the manifest: restart [containers...]",
+			Action: lifecycleCommand((*compose.Compose).RestartAction),
+			Flags: append([]cli.Flag{
+				cli.DurationFlag{
+					Name:  "wait",
+					Value: 1 * time.Second,
+					Usage: "Wait and check exit codes of started containers",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "pause",
+			Usage:  "pause running containers of the manifest: pause [containers...]",
+			Action: lifecycleCommand((*compose.Compose).PauseAction),
+			Flags:  composeFlags,
+		},
+		{
+			Name:   "unpause",
+			Usage:  "unpause paused containers of the manifest: unpause [containers...]",
+			Action: lifecycleCommand((*compose.Compose).UnpauseAction),
+			Flags:  composeFlags,
+		},
+		{
+			Name:   "ps",
+			Usage:  "list containers managed by rocker-compose on the host, no manifest needed",
+			Action: psCommand,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "namespace, n",
+					Usage: "List containers of the given namespace only",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Value: "table",
+					Usage: "Output format: table|json",
+				},
 			},
 		},
+		{
+			Name:   "logs",
+			Usage:  "print logs of containers of the namespace, interleaved: logs [containers...]",
+			Action: logsCommand,
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "follow",
+					Usage: "Keep streaming new lines until containers stop",
+				},
+				cli.StringFlag{
+					Name:  "since",
+					Usage: "Show lines since the timestamp (RFC 3339) or relative to now duration, e.g. 10m",
+				},
+				cli.StringFlag{
+					Name:  "tail",
+					Value: "all",
+					Usage: "Number of lines to show from the end of the logs",
+				},
+				cli.BoolFlag{
+					Name:  "timestamps, t",
+					Usage: "Show timestamps",
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "Print a JSON object per line",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "exec",
+			Usage:  "run a command in a running container of the manifest: exec <container> -- <command> [args...]",
+			Action: execCommand,
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "no-tty, T",
+					Usage: "Do not allocate a TTY even if stdin is a terminal",
+				},
+				cli.StringFlag{
+					Name:  "user, u",
+					Usage: "Run the command as the given user, e.g. root or 1000:1000",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "status",
+			Usage:  "report containers that differ from the manifest, exit with non-zero code on drift",
+			Action: statusCommand,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Value: "text",
+					Usage: "Output format: text|json",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "watch",
+			Usage:  "keep the manifest applied, correcting containers that were killed, removed or modified",
+			Action: watchCommand,
+			Flags: append([]cli.Flag{
+				cli.DurationFlag{
+					Name:  "interval",
+					Value: 30 * time.Second,
+					Usage: "Reconcile containers with the manifest at least this often",
+				},
+				cli.BoolFlag{
+					Name:  "pull",
+					Usage: "Do pull images on every reconciliation",
+				},
+				cli.DurationFlag{
+					Name:  "wait",
+					Value: 1 * time.Second,
+					Usage: "Wait and check exit codes of launched containers",
+				},
+				cli.BoolFlag{
+					Name:  "blue-green",
+					Usage: "Start changed containers next to the old ones and remove the old ones only when new are up",
+				},
+				cli.BoolFlag{
+					Name:  "rollback-on-failure",
+					Usage: "Restore removed containers from their previous specs if execution fails",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "backup",
+			Usage:  "stream data of the container volumes to a tar archive: backup <container>",
+			Action: backupCommand,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "output, O",
+					Value: "-",
+					Usage: "write the archive to a file or stdout if the value is `-`",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "restore",
+			Usage:  "restore data of the container volumes from a tar archive made by backup: restore <container>",
+			Action: restoreCommand,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "input, i",
+					Value: "-",
+					Usage: "read the archive from a file or stdin if the value is `-`",
+				},
+			}, composeFlags...),
+		},
 		dockerclient.InfoCommandSpec(),
 	}
 
@@ -246,10 +525,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := app.Run(os.Args); err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
-	}
+	return app
 }
 
 func runCommand(ctx *cli.Context) {
@@ -271,14 +547,19 @@ func runCommand(ctx *cli.Context) {
 	auth := initAuthConfig(ctx)
 
 	compose, err := compose.New(&compose.Config{
-		Manifest: config,
-		Docker:   dockerCli,
-		Force:    ctx.Bool("force"),
-		DryRun:   ctx.Bool("dry"),
-		Attach:   ctx.Bool("attach"),
-		Wait:     ctx.Duration("wait"),
-		Pull:     ctx.Bool("pull"),
-		Auth:     auth,
+		Manifest:     config,
+		Docker:       dockerCli,
+		Force:        ctx.Bool("force"),
+		DryRun:       ctx.Bool("dry"),
+		Attach:       ctx.Bool("attach"),
+		Wait:         ctx.Duration("wait"),
+		Pull:         ctx.Bool("pull"),
+		Auth:         auth,
+		Only:         ctx.Args(),
+		BlueGreen:    ctx.Bool("blue-green"),
+		Rollback:     ctx.Bool("rollback-on-failure"),
+		History:      initHistory(ctx, dockerCli),
+		PruneVolumes: ctx.Bool("prune-volumes"),
 	})
 
 	if err != nil {
@@ -287,7 +568,7 @@ func runCommand(ctx *cli.Context) {
 
 	// in case of --force given, first remove all existing containers
 	if ctx.Bool("force") {
-		if err := doRemove(ctx, config, dockerCli, auth); err != nil {
+		if err := doRemove(ctx, config, dockerCli, auth, ctx.Args(), false); err != nil {
 			fatalf(err)
 		}
 	}
@@ -302,6 +583,122 @@ func runCommand(ctx *cli.Context) {
 	}
 }
 
+func planCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest:  config,
+		Docker:    dockerCli,
+		Pull:      ctx.Bool("pull"),
+		Auth:      auth,
+		Only:      ctx.Args(),
+		BlueGreen: ctx.Bool("blue-green"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	plan, err := compose.PlanAction()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if out := ctx.String("out"); out != "" {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(out, append(data, '\n'), 0600); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Plan is saved to %s", out)
+	}
+
+	switch ctx.String("format") {
+	case "json":
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(data))
+	case "text":
+		if _, err := plan.WriteTo(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("Unknown output format: %s", ctx.String("format"))
+	}
+}
+
+func applyCommand(ctx *cli.Context) {
+	ansibleResp := initAnsubleResp(ctx)
+
+	fatalf := func(err error) {
+		if ansibleResp != nil {
+			ansibleResp.Error(err).WriteTo(os.Stdout)
+		}
+		log.Fatal(err)
+	}
+
+	initLogs(ctx)
+
+	var (
+		file           = ctx.String("plan")
+		fd   io.Reader = os.Stdin
+		err  error
+	)
+
+	if file == "" {
+		fatalf(fmt.Errorf("Plan file is not specified, use --plan"))
+	}
+
+	if file != "-" {
+		if fd, err = os.Open(file); err != nil {
+			fatalf(err)
+		}
+		defer fd.(io.ReadCloser).Close()
+	}
+
+	plan, err := compose.ReadPlan(fd)
+	if err != nil {
+		fatalf(err)
+	}
+
+	dockerCli := initDockerClient(ctx)
+	auth := initAuthConfig(ctx)
+
+	// secrets are saved in the plan only as hashes, their values are in the manifest
+	var manifest *config.Config
+	if plan.HasHashedSecrets() {
+		manifest = initComposeConfig(ctx, dockerCli)
+	}
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: manifest,
+		Docker:   dockerCli,
+		DryRun:   ctx.Bool("dry"),
+		Wait:     ctx.Duration("wait"),
+		Auth:     auth,
+		Rollback: ctx.Bool("rollback-on-failure"),
+		History:  initHistory(ctx, dockerCli),
+	})
+	if err != nil {
+		fatalf(err)
+	}
+
+	if err := compose.ApplyAction(plan); err != nil {
+		fatalf(err)
+	}
+
+	if ansibleResp != nil {
+		compose.WritePlan(ansibleResp).WriteTo(os.Stdout)
+	}
+}
+
 func pullCommand(ctx *cli.Context) {
 	ansibleResp := initAnsubleResp(ctx)
 
@@ -345,7 +742,7 @@ func rmCommand(ctx *cli.Context) {
 	config := initComposeConfig(ctx, dockerCli)
 	auth := initAuthConfig(ctx)
 
-	if err := doRemove(ctx, config, dockerCli, auth); err != nil {
+	if err := doRemove(ctx, config, dockerCli, auth, nil, ctx.Bool("prune-volumes")); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -392,11 +789,16 @@ func tarCommand(ctx *cli.Context) {
 	initLogs(ctx)
 
 	var (
-		file   = ctx.String("file")
+		files  = manifestFiles(ctx)
+		file   = files[0]
 		output = ctx.String("output")
 		prefix = ctx.String("prefix")
 	)
 
+	if len(files) > 1 {
+		log.Fatalf("Only one manifest file can be packed to tar, given: %s", strings.Join(files, ", "))
+	}
+
 	// TODO: test logs
 	if output == "-" && !ctx.GlobalIsSet("verbose") {
 		log.SetLevel(log.WarnLevel)
@@ -422,12 +824,19 @@ func recoverCommand(ctx *cli.Context) {
 	dockerCli := initDockerClient(ctx)
 	auth := initAuthConfig(ctx)
 
+	// the manifest is optional, it is only needed to take contents of secrets from
+	var manifest *config.Config
+	if len(ctx.StringSlice("file")) > 0 {
+		manifest = initComposeConfig(ctx, dockerCli)
+	}
+
 	compose, err := compose.New(&compose.Config{
-		Docker:  dockerCli,
-		DryRun:  ctx.Bool("dry"),
-		Wait:    ctx.Duration("wait"),
-		Recover: true,
-		Auth:    auth,
+		Manifest: manifest,
+		Docker:   dockerCli,
+		DryRun:   ctx.Bool("dry"),
+		Wait:     ctx.Duration("wait"),
+		Recover:  true,
+		Auth:     auth,
 	})
 
 	if err != nil {
@@ -439,6 +848,395 @@ func recoverCommand(ctx *cli.Context) {
 	}
 }
 
+func historyCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+
+	revisions, err := initHistory(ctx, dockerCli).List(config.Namespace)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(revisions) == 0 {
+		fmt.Printf("No revisions of namespace %s\n", config.Namespace)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tDATE\tDESCRIPTION\tIMAGES")
+	for _, rev := range revisions {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", rev.Number, rev.Timestamp.Format(time.RFC3339),
+			rev.Description, strings.Join(rev.Images(), " "))
+	}
+	w.Flush()
+}
+
+func rollbackCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+	history := initHistory(ctx, dockerCli)
+
+	revisions, err := history.List(config.Namespace)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rev *compose.Revision
+
+	if to := ctx.Int("to"); to > 0 {
+		if rev, err = history.Get(config.Namespace, to); err != nil {
+			log.Fatal(err)
+		}
+	} else if len(revisions) < 2 {
+		log.Fatalf("Nothing to roll back to, namespace %s has %d revision(s)", config.Namespace, len(revisions))
+	} else {
+		rev = revisions[len(revisions)-2]
+	}
+
+	compose, err := compose.New(&compose.Config{
+		Manifest:  config,
+		Docker:    dockerCli,
+		DryRun:    ctx.Bool("dry"),
+		Wait:      ctx.Duration("wait"),
+		Auth:      auth,
+		BlueGreen: ctx.Bool("blue-green"),
+		History:   history,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := compose.RollbackAction(rev); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// lifecycleCommand makes the action of start, stop, restart, pause and unpause
+// commands, which only differ by the method of Compose they call
+func lifecycleCommand(action func(*compose.Compose) error) func(ctx *cli.Context) {
+	return func(ctx *cli.Context) {
+		initLogs(ctx)
+
+		dockerCli := initDockerClient(ctx)
+		config := initComposeConfig(ctx, dockerCli)
+
+		compose, err := compose.New(&compose.Config{
+			Manifest: config,
+			Docker:   dockerCli,
+			DryRun:   ctx.Bool("dry"),
+			Wait:     ctx.Duration("wait"),
+			Only:     ctx.Args(),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := action(compose); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func psCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	dockerCli := initDockerClient(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Docker: dockerCli,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	containers, err := compose.PsAction(ctx.String("namespace"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch ctx.String("format") {
+	case "json":
+		data, err := json.MarshalIndent(containers, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(data))
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "NAMESPACE\tNAME\tIMAGE\tIMAGE ID\tSTATE\tEXIT CODE\tUPTIME\tPORTS\tROCKER-COMPOSE-ID")
+		for _, c := range containers {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.12s\t%s\t%d\t%s\t%s\t%s\n", c.Namespace, c.Name, c.Image,
+				strings.TrimPrefix(c.ImageID, "sha256:"), c.State, c.ExitCode, c.Uptime, strings.Join(c.Ports, ", "), c.ComposeID)
+		}
+		w.Flush()
+	default:
+		log.Fatalf("Unknown output format: %s", ctx.String("format"))
+	}
+}
+
+func logsCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	since, err := compose.ParseLogsSince(ctx.String("since"), time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := compose.LogsOptions{
+		Follow:     ctx.Bool("follow"),
+		Since:      since,
+		Tail:       ctx.String("tail"),
+		Timestamps: ctx.Bool("timestamps"),
+		JSON:       ctx.Bool("json"),
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := compose.LogsAction(ctx.Args(), opts, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func execCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	args := ctx.Args()
+	if len(args) == 0 {
+		log.Fatal("Container name is not specified, usage: exec <container> -- <command> [args...]")
+	}
+
+	name, cmd := args[0], args[1:]
+	if len(cmd) > 0 && cmd[0] == "--" {
+		cmd = cmd[1:]
+	}
+	if len(cmd) == 0 {
+		log.Fatal("Command is not specified, usage: exec <container> -- <command> [args...]")
+	}
+
+	fd, isTerminal := term.GetFdInfo(os.Stdin)
+
+	opts := compose.ExecOptions{
+		Cmd:    cmd,
+		User:   ctx.String("user"),
+		Tty:    isTerminal && !ctx.Bool("no-tty"),
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// log.Fatal and os.Exit do not run deferred calls, so the terminal is restored explicitly
+	restoreTerminal := func() {}
+
+	if opts.Tty {
+		if size, err := term.GetWinsize(fd); err == nil {
+			opts.Height, opts.Width = int(size.Height), int(size.Width)
+		}
+		state, err := term.SetRawTerminal(fd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		restoreTerminal = func() { term.RestoreTerminal(fd, state) }
+	}
+
+	exitCode, err := compose.ExecAction(name, opts)
+	restoreTerminal()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(exitCode)
+}
+
+func statusCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	status, err := compose.StatusAction()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch ctx.String("format") {
+	case "json":
+		data, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(data))
+	case "text":
+		if _, err := status.WriteTo(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("Unknown output format: %s", ctx.String("format"))
+	}
+
+	if !status.InSync() {
+		os.Exit(1)
+	}
+}
+
+func watchCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	interval := ctx.Duration("interval")
+	if interval <= 0 {
+		log.Fatalf("Reconcile interval should be positive, got %s", interval)
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest:  config,
+		Docker:    dockerCli,
+		DryRun:    ctx.Bool("dry"),
+		Wait:      ctx.Duration("wait"),
+		Pull:      ctx.Bool("pull"),
+		Auth:      auth,
+		BlueGreen: ctx.Bool("blue-green"),
+		Rollback:  ctx.Bool("rollback-on-failure"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Infof("Got %s, stopping", sig)
+		close(stop)
+	}()
+
+	if err := compose.WatchAction(interval, stop); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func backupCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	var (
+		name           = ctx.Args().First()
+		file           = ctx.String("output")
+		fd   io.Writer = os.Stdout
+		err  error
+	)
+
+	if name == "" {
+		log.Fatal("Container name is not specified, usage: backup <container>")
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if file != "-" {
+		if fd, err = os.Create(file); err != nil {
+			log.Fatal(err)
+		}
+		defer fd.(io.WriteCloser).Close()
+	}
+
+	if err := compose.BackupAction(name, fd); err != nil {
+		if file != "-" {
+			os.Remove(file)
+		}
+		log.Fatal(err)
+	}
+}
+
+func restoreCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	var (
+		name           = ctx.Args().First()
+		file           = ctx.String("input")
+		fd   io.Reader = os.Stdin
+		err  error
+	)
+
+	if name == "" {
+		log.Fatal("Container name is not specified, usage: restore <container>")
+	}
+
+	if file != "-" {
+		if fd, err = os.Open(file); err != nil {
+			log.Fatal(err)
+		}
+		defer fd.(io.ReadCloser).Close()
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := compose.RestoreAction(name, fd); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func initHistory(ctx *cli.Context, dockerCli *docker.Client) *compose.History {
+	dir := ctx.GlobalString("history-dir")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".rocker-compose", "history")
+	}
+	return compose.NewHistory(dir, dockerCli.Endpoint())
+}
+
 func initLogs(ctx *cli.Context) {
 	logger := log.StandardLogger()
 
@@ -480,8 +1278,20 @@ func initLogs(ctx *cli.Context) {
 	}
 }
 
+// manifestFiles returns the manifest files given with --file, compose.yml by default
+func manifestFiles(ctx *cli.Context) []string {
+	files := ctx.StringSlice("file")
+	if len(files) == 0 {
+		return []string{"compose.yml"}
+	}
+	return files
+}
+
 func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Config {
-	file := ctx.String("file")
+	var (
+		files = manifestFiles(ctx)
+		file  = files[0]
+	)
 
 	if file == "" {
 		log.Fatalf("Manifest file is empty")
@@ -492,9 +1302,8 @@ func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Confi
 		manifest *config.Config
 		err      error
 		bridgeIP *string
-		fd       io.Reader = os.Stdin
-		isTar              = ctx.Bool("tar")
-		print              = ctx.Bool("print")
+		isTar    = ctx.Bool("tar")
+		print    = ctx.Bool("print")
 	)
 
 	vars := initVars(ctx)
@@ -518,6 +1327,55 @@ func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Confi
 		},
 	}
 
+	if len(files) > 1 {
+		for _, f := range files {
+			if f == "-" || isTar || filepath.Ext(f) == ".tar" {
+				log.Fatalf("Multiple manifest files cannot be read from STDIN or tar, given: %s", strings.Join(files, ", "))
+			}
+		}
+
+		if !print {
+			log.Infof("Reading manifests: %s", strings.Join(files, ", "))
+		}
+
+		if manifest, err = config.NewFromFiles(files, vars, funcs, print); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		manifest = readSingleManifest(file, isTar, print, vars, funcs)
+	}
+
+	// Timeout for docker daemon to respond after accepting connection
+	dockerCli.SetTimeout(ctx.GlobalDuration("docker-ping-timeout"))
+	defer dockerCli.SetTimeout(0 * time.Second)
+
+	max := ctx.GlobalInt("docker-ping-retries")
+	for i := 1; i <= max; i++ {
+		var err error
+
+		if err = dockerCli.Ping(); err == nil {
+			return manifest
+		}
+
+		log.Infof("Error connecting to docker endpoint %s, attempt %d/%d, error: %s", dockerCli.Endpoint(), i, max, err)
+		time.Sleep(1 * time.Second)
+	}
+
+	log.Fatalf("Unable to connect to docker endpoint %s", dockerCli.Endpoint())
+	os.Exit(1)
+
+	return manifest
+}
+
+// readSingleManifest reads the manifest from the file, from STDIN if the file is "-",
+// or from compose.yml inside of the tar archive, along with its variables.yml
+func readSingleManifest(file string, isTar, print bool, vars template.Vars, funcs map[string]interface{}) *config.Config {
+	var (
+		manifest *config.Config
+		err      error
+		fd       io.Reader = os.Stdin
+	)
+
 	if file != "-" {
 		if !print {
 			log.Infof("Reading manifest: %s", file)
@@ -607,25 +1465,6 @@ func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Confi
 		log.Fatal(err)
 	}
 
-	// Timeout for docker daemon to respond after accepting connection
-	dockerCli.SetTimeout(ctx.GlobalDuration("docker-ping-timeout"))
-	defer dockerCli.SetTimeout(0 * time.Second)
-
-	max := ctx.GlobalInt("docker-ping-retries")
-	for i := 1; i <= max; i++ {
-		var err error
-
-		if err = dockerCli.Ping(); err == nil {
-			return manifest
-		}
-
-		log.Infof("Error connecting to docker endpoint %s, attempt %d/%d, error: %s", dockerCli.Endpoint(), i, max, err)
-		time.Sleep(1 * time.Second)
-	}
-
-	log.Fatalf("Unable to connect to docker endpoint %s", dockerCli.Endpoint())
-	os.Exit(1)
-
 	return manifest
 }
 
@@ -692,13 +1531,15 @@ func initAnsubleResp(ctx *cli.Context) (ansibleResp *ansible.Response) {
 	return
 }
 
-func doRemove(ctx *cli.Context, config *config.Config, dockerCli *docker.Client, auth *docker.AuthConfigurations) error {
+func doRemove(ctx *cli.Context, config *config.Config, dockerCli *docker.Client, auth *docker.AuthConfigurations, only []string, pruneVolumes bool) error {
 	compose, err := compose.New(&compose.Config{
-		Manifest: config,
-		Docker:   dockerCli,
-		DryRun:   ctx.Bool("dry"),
-		Remove:   true,
-		Auth:     auth,
+		Manifest:     config,
+		Docker:       dockerCli,
+		DryRun:       ctx.Bool("dry"),
+		Remove:       true,
+		Auth:         auth,
+		Only:         only,
+		PruneVolumes: pruneVolumes,
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,47 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/codegangsta/cli"
+)
+
+// TestFlags builds the flag set of every command the same way cli does,
+// so a flag or alias defined twice fails here instead of panicking at runtime
+func TestFlags(t *testing.T) {
+	app := newApp()
+
+	apply := func(name string, flags []cli.Flag) {
+		defer func() {
+			if err := recover(); err != nil {
+				t.Errorf("Flags of %s are invalid: %s", name, err)
+			}
+		}()
+		set := flag.NewFlagSet(name, flag.ContinueOnError)
+		for _, f := range flags {
+			f.Apply(set)
+		}
+	}
+
+	apply(app.Name, app.Flags)
+	for _, command := range app.Commands {
+		apply(command.Name, command.Flags)
+	}
+}
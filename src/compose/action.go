@@ -20,8 +20,14 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
 )
 
+// nextContainerSuffix is appended to the name of the container
+// started next to the existing one during blue/green replacement
+const nextContainerSuffix = "__next"
+
 // Action interface describes action that can be done by rocker-compose docker client
 type Action interface {
 	Execute(client Client) error
@@ -38,6 +44,13 @@ type removeContainer action
 type noAction action
 type waitContainerAction action
 
+// updateContainer changes the existing container in place,
+// the spec is taken from container and the id from actual
+type updateContainer struct {
+	container *Container
+	actual    *Container
+}
+
 // NoAction is an empty action which does nothing
 var NoAction = &noAction{}
 
@@ -46,6 +59,20 @@ type stepAction struct {
 	async   bool
 }
 
+// replaceContainer starts a new container next to the existing one
+// and removes the old one only once the new one is up, see NewReplaceContainerAction
+type replaceContainer struct {
+	container *Container
+	actual    *Container
+}
+
+// migrateContainer recreates the container copying data of its volumes
+// from the existing one, see NewMigrateContainerAction
+type migrateContainer struct {
+	container *Container
+	actual    *Container
+}
+
 // NewStepAction makes a "step" wrapper which holds the list of actions that may run in parallel.
 // Multiple steps can only run one by one. Steps can be nested.
 func NewStepAction(async bool, actions ...Action) Action {
@@ -98,6 +125,28 @@ func NewRemoveContainerAction(c *Container) Action {
 	return &removeContainer{container: c}
 }
 
+// NewUpdateContainerAction makes action that updates the existing container
+// in place to match the given spec, see Container.CanUpdateLive
+func NewUpdateContainerAction(c *Container, actual *Container) Action {
+	return &updateContainer{container: c, actual: actual}
+}
+
+// NewReplaceContainerAction makes action that replaces the existing container
+// with the new one without a downtime window (blue/green). The new container is
+// started under a temporary name, verified, then the old one is removed and
+// the new one is renamed to the canonical name.
+func NewReplaceContainerAction(c *Container, actual *Container) Action {
+	return &replaceContainer{container: c, actual: actual}
+}
+
+// NewMigrateContainerAction makes action that recreates the container keeping the data
+// of its volumes (migrate_volumes). The new container is created under a temporary name,
+// the data is copied from the old one, then the old one is removed and the new one is
+// renamed to the canonical name.
+func NewMigrateContainerAction(c *Container, actual *Container) Action {
+	return &migrateContainer{container: c, actual: actual}
+}
+
 // Execute runs the step
 func (a *stepAction) Execute(client Client) (err error) {
 	if a.async {
@@ -170,6 +219,92 @@ func (a *removeContainer) String() string {
 	return fmt.Sprintf("Removing container '%s'", a.container.Name)
 }
 
+// Execute updates a container
+func (a *updateContainer) Execute(client Client) (err error) {
+	a.container.ID = a.actual.ID
+	err = client.UpdateContainer(a.container)
+	return
+}
+
+// String returns the printable string representation of the updateContainer action.
+func (a *updateContainer) String() string {
+	return fmt.Sprintf("Updating container '%s' in place", a.container.Name)
+}
+
+// Execute replaces a container
+func (a *replaceContainer) Execute(client Client) (err error) {
+	next := *a.container
+	next.Name = &config.ContainerName{
+		Namespace: a.container.Name.Namespace,
+		Name:      a.container.Name.Name + nextContainerSuffix,
+	}
+
+	if err = client.RunContainer(&next); err != nil {
+		return
+	}
+
+	// the old container is kept intact if the new one did not come up
+	if err = client.EnsureContainerState(&next); err != nil {
+		if removeErr := client.RemoveContainer(&next); removeErr != nil {
+			return fmt.Errorf("%s, also failed to remove %s, error: %s", err, next.Name, removeErr)
+		}
+		return
+	}
+
+	if err = client.RemoveContainer(a.actual); err != nil {
+		return
+	}
+
+	if err = client.RenameContainer(&next, a.container.Name); err != nil {
+		return
+	}
+
+	a.container.ID = next.ID
+	return
+}
+
+// String returns the printable string representation of the replaceContainer action.
+func (a *replaceContainer) String() string {
+	return fmt.Sprintf("Replacing container '%s' without downtime", a.container.Name)
+}
+
+// Execute migrates a container
+func (a *migrateContainer) Execute(client Client) (err error) {
+	next := *a.container
+	next.Name = &config.ContainerName{
+		Namespace: a.container.Name.Namespace,
+		Name:      a.container.Name.Name + nextContainerSuffix,
+	}
+
+	if err = client.RunContainer(&next); err != nil {
+		return
+	}
+
+	// the old container is kept intact if the data was not copied
+	if err = client.MigrateVolumes(a.actual, &next); err != nil {
+		if removeErr := client.RemoveContainer(&next); removeErr != nil {
+			return fmt.Errorf("%s, also failed to remove %s, error: %s", err, next.Name, removeErr)
+		}
+		return
+	}
+
+	if err = client.RemoveContainer(a.actual); err != nil {
+		return
+	}
+
+	if err = client.RenameContainer(&next, a.container.Name); err != nil {
+		return
+	}
+
+	a.container.ID = next.ID
+	return
+}
+
+// String returns the printable string representation of the migrateContainer action.
+func (a *migrateContainer) String() string {
+	return fmt.Sprintf("Recreating container '%s' migrating its volumes", a.container.Name)
+}
+
 // Execute waits for a container
 func (a *waitContainerAction) Execute(client Client) (err error) {
 	return client.WaitForContainer(a.container)
@@ -30,11 +30,12 @@ type Response struct {
 	Message string              `json:"msg"`
 	Removed []ResponseContainer `json:"removed"`
 	Created []ResponseContainer `json:"created"`
+	Updated []ResponseContainer `json:"updated"`
 	Pulled  []string            `json:"pulled"`
 	Cleaned []string            `json:"cleaned"`
 }
 
-// ResponseContainer describes added or removed container
+// ResponseContainer describes added, updated or removed container
 type ResponseContainer struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
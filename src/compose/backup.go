@@ -0,0 +1,234 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// BackupVolumes streams the contents of volumes of the container, including those mounted
+// from data containers with volumes_from, to the writer as a single tar archive. Entries
+// of the archive are named by absolute paths inside of the container without the leading slash,
+// e.g. var/lib/mysql/ibdata1. Host directories mounted to the container are not included.
+func (client *DockerClient) BackupVolumes(container *Container, w io.Writer) error {
+	inspect, err := client.Docker.InspectContainer(container.Name.String())
+	if err != nil {
+		return err
+	}
+
+	volumes := volumeDestinations(inspect)
+	if len(volumes) == 0 {
+		return fmt.Errorf("Container %s has no volumes", container.Name)
+	}
+
+	tw := tar.NewWriter(w)
+
+	for _, dest := range volumes {
+		log.Infof("Backing up volume %s of container %s", dest, container.Name)
+
+		pr, pw := io.Pipe()
+		go func(dest string) {
+			pw.CloseWithError(client.Docker.DownloadFromContainer(inspect.ID, docker.DownloadFromContainerOptions{
+				OutputStream: pw,
+				Path:         dest,
+			}))
+		}(dest)
+
+		if err := copyVolumeArchive(tw, pr, dest); err != nil {
+			pr.CloseWithError(err)
+			return fmt.Errorf("Failed to back up volume %s of container %s, error: %s", dest, container.Name, err)
+		}
+	}
+
+	return tw.Close()
+}
+
+// RestoreVolumes extracts the tar archive made by BackupVolumes to volumes of the container.
+// Entries that do not belong to any volume of the container are skipped.
+func (client *DockerClient) RestoreVolumes(container *Container, r io.Reader) error {
+	inspect, err := client.Docker.InspectContainer(container.Name.String())
+	if err != nil {
+		return err
+	}
+
+	volumes := volumeDestinations(inspect)
+	if len(volumes) == 0 {
+		return fmt.Errorf("Container %s has no volumes", container.Name)
+	}
+	if inspect.State.Running {
+		log.Warnf("Container %s is running, the application may overwrite restored data", container.Name)
+	}
+
+	log.Infof("Restoring volumes %s of container %s", strings.Join(volumes, ", "), container.Name)
+
+	pr, pw := io.Pipe()
+	go func() {
+		skipped, err := filterVolumeArchive(pw, r, volumes)
+		if skipped > 0 {
+			log.Warnf("Skipped %d entries of the archive that do not belong to volumes of container %s", skipped, container.Name)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	if err := client.Docker.UploadToContainer(inspect.ID, docker.UploadToContainerOptions{
+		InputStream: pr,
+		Path:        "/",
+	}); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("Failed to restore volumes of container %s, error: %s", container.Name, err)
+	}
+
+	return nil
+}
+
+// MigrateVolumes copies data of volumes of one container to another one, streaming
+// the backup of the first container right to the restore of the second one.
+// Nothing is copied if the first container has no volumes.
+func (client *DockerClient) MigrateVolumes(from, to *Container) error {
+	inspect, err := client.Docker.InspectContainer(from.Name.String())
+	if err != nil {
+		return err
+	}
+	if len(volumeDestinations(inspect)) == 0 {
+		log.Warnf("Container %s has no volumes, nothing to migrate", from.Name)
+		return nil
+	}
+
+	log.Infof("Migrating volumes of container %s to %s", from.Name, to.Name)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(client.BackupVolumes(from, pw))
+	}()
+
+	if err := client.RestoreVolumes(to, pr); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("Failed to migrate volumes of container %s, error: %s", from.Name, err)
+	}
+
+	return nil
+}
+
+// volumeDestinations returns the sorted list of paths inside of the container
+// where docker volumes are mounted; bind mounts of host directories have no name
+func volumeDestinations(inspect *docker.Container) []string {
+	volumes := []string{}
+	for _, mount := range inspect.Mounts {
+		if mount.Name != "" {
+			volumes = append(volumes, mount.Destination)
+		}
+	}
+	sort.Strings(volumes)
+	return volumes
+}
+
+// copyVolumeArchive copies entries of the archive given by docker for the volume path
+// to the writer. Docker names entries by the base name of the path, e.g. mysql/ibdata1,
+// so they are renamed to the full path, e.g. var/lib/mysql/ibdata1.
+func copyVolumeArchive(tw *tar.Writer, r io.Reader, dest string) error {
+	var (
+		tr     = tar.NewReader(r)
+		base   = path.Base(dest)
+		prefix = strings.TrimPrefix(path.Clean(dest), "/")
+	)
+
+	rename := func(name string) (string, bool) {
+		if name != base && !strings.HasPrefix(name, base+"/") {
+			return "", false
+		}
+		return prefix + strings.TrimPrefix(name, base), true
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name, ok := rename(hdr.Name)
+		if !ok {
+			continue
+		}
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeLink {
+			if hdr.Linkname, ok = rename(hdr.Linkname); !ok {
+				continue
+			}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// filterVolumeArchive copies entries of the archive that belong to the given volumes
+// to the writer and returns the number of skipped entries
+func filterVolumeArchive(w io.Writer, r io.Reader, volumes []string) (skipped int, err error) {
+	var (
+		tr = tar.NewReader(r)
+		tw = tar.NewWriter(w)
+	)
+
+	belongs := func(name string) bool {
+		name = strings.TrimSuffix(name, "/")
+		for _, volume := range volumes {
+			prefix := strings.TrimPrefix(path.Clean(volume), "/")
+			if name == prefix || strings.HasPrefix(name, prefix+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return skipped, tw.Close()
+		}
+		if err != nil {
+			return skipped, fmt.Errorf("Failed to read the archive, error: %s", err)
+		}
+
+		if !belongs(hdr.Name) {
+			log.Debugf("Skip %s, it does not belong to any volume", hdr.Name)
+			skipped++
+			continue
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return skipped, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return skipped, err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupVolumeDestinations(t *testing.T) {
+	inspect := &docker.Container{
+		Mounts: []docker.Mount{
+			{Name: "bbb", Destination: "/var/lib/mysql"},
+			{Source: "/etc/hosts", Destination: "/etc/hosts"},
+			{Name: "aaa", Destination: "/data"},
+		},
+	}
+	assert.Equal(t, []string{"/data", "/var/lib/mysql"}, volumeDestinations(inspect))
+}
+
+func TestBackupCopyVolumeArchive(t *testing.T) {
+	// docker names entries by the base name of the requested path
+	src := makeTestTar(t, map[string]string{
+		"mysql/":        "",
+		"mysql/ibdata1": "data",
+		"mysqlx":        "other",
+	})
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := copyVolumeArchive(tw, src, "/var/lib/mysql"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string]string{
+		"var/lib/mysql/":        "",
+		"var/lib/mysql/ibdata1": "data",
+	}, readTestTar(t, &buf))
+}
+
+func TestBackupFilterVolumeArchive(t *testing.T) {
+	src := makeTestTar(t, map[string]string{
+		"var/lib/mysql/":        "",
+		"var/lib/mysql/ibdata1": "data",
+		"data/file":             "file",
+		"etc/passwd":            "root",
+	})
+
+	var buf bytes.Buffer
+	skipped, err := filterVolumeArchive(&buf, src, []string{"/var/lib/mysql", "/data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, skipped)
+	assert.Equal(t, map[string]string{
+		"var/lib/mysql/":        "",
+		"var/lib/mysql/ibdata1": "data",
+		"data/file":             "file",
+	}, readTestTar(t, &buf))
+}
+
+func makeTestTar(t *testing.T, files map[string]string) io.Reader {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if name[len(name)-1] == '/' {
+			hdr.Mode, hdr.Typeflag = 0755, tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func readTestTar(t *testing.T, r io.Reader) map[string]string {
+	files := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(content)
+	}
+}
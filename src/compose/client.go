@@ -18,7 +18,10 @@ package compose
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/grammarly/rocker-compose/src/compose/config"
@@ -34,12 +37,41 @@ import (
 	"github.com/fsouza/go-dockerclient"
 )
 
+// healthPollInterval is how often the health status of a starting container is checked
+const healthPollInterval = time.Second
+
+// Defaults of docker healthchecks, used if neither the spec nor the image sets them
+const (
+	healthDefaultInterval = 30 * time.Second
+	healthDefaultTimeout  = 30 * time.Second
+	healthDefaultRetries  = 3
+)
+
 // Client interface describes a rocker-compose client that can do various operations
 // needed for rocker-compose to make changes.
 type Client interface {
 	GetContainers(global bool) ([]*Container, error)
 	RemoveContainer(container *Container) error
 	RunContainer(container *Container) error
+	UpdateContainer(container *Container) error
+	RenameContainer(container *Container, name *config.ContainerName) error
+	GetNetworks(ns string) ([]*Network, error)
+	CreateNetwork(network *Network) error
+	RemoveNetwork(network *Network) error
+	RecreateNetwork(network, actual *Network) error
+	GetVolumes(ns string) ([]*Volume, error)
+	CreateVolume(volume *Volume) error
+	RemoveVolume(volume *Volume) error
+	BackupVolumes(container *Container, w io.Writer) error
+	RestoreVolumes(container *Container, r io.Reader) error
+	MigrateVolumes(from, to *Container) error
+	WatchContainers(ns string, changes chan<- string, stop <-chan struct{}) error
+	ContainerLogs(container *Container, stdout, stderr io.Writer, opts LogsOptions) error
+	StartContainer(container *Container) error
+	StopContainer(container *Container) error
+	PauseContainer(container *Container) error
+	UnpauseContainer(container *Container) error
+	ExecContainer(container *Container, opts ExecOptions) (int, error)
 	EnsureContainerExist(name *Container) error
 	EnsureContainerState(name *Container) error
 	PullAll(containers []*Container, vars template.Vars) error
@@ -187,6 +219,54 @@ func (client *DockerClient) RemoveContainer(container *Container) error {
 	return nil
 }
 
+// UpdateContainer implements changing resource limits and restart policy
+// of the existing container given by container.ID
+func (client *DockerClient) UpdateContainer(container *Container) error {
+	log.Infof("Updating container %s id:%.12s in place", container.Name, container.ID)
+
+	var (
+		apiConfig  = container.Config.GetAPIConfig()
+		hostConfig = container.Config.GetAPIHostConfig()
+	)
+
+	opts := docker.UpdateContainerOptions{
+		Memory:        int(hostConfig.Memory),
+		MemorySwap:    int(hostConfig.MemorySwap),
+		CPUShares:     int(apiConfig.CPUShares),
+		CpusetCpus:    hostConfig.CPUSet,
+		RestartPolicy: hostConfig.RestartPolicy,
+	}
+
+	// keep the default of 'docker run', otherwise raising memory
+	// above the swap limit set at creation would be refused
+	if opts.Memory > 0 && opts.MemorySwap == 0 {
+		opts.MemorySwap = opts.Memory * 2
+	}
+
+	log.Debugf("Updating container with opts: %# v", pretty.Formatter(opts))
+
+	if err := client.Docker.UpdateContainer(container.ID, opts); err != nil {
+		return fmt.Errorf("Failed to update container, error: %s", err)
+	}
+
+	return nil
+}
+
+// RenameContainer implements renaming the existing container given by container.ID
+func (client *DockerClient) RenameContainer(container *Container, name *config.ContainerName) error {
+	log.Infof("Renaming container %s id:%.12s to %s", container.Name, container.ID, name)
+
+	opts := docker.RenameContainerOptions{
+		ID:   container.ID,
+		Name: name.String(),
+	}
+	if err := client.Docker.RenameContainer(opts); err != nil {
+		return fmt.Errorf("Failed to rename container, error: %s", err)
+	}
+
+	return nil
+}
+
 // RunContainer implements creating and optionally running a container
 // depending on its state preference.
 func (client *DockerClient) RunContainer(container *Container) error {
@@ -204,6 +284,26 @@ func (client *DockerClient) RunContainer(container *Container) error {
 	}
 	container.ID = apiContainer.ID
 
+	// the container is created in its first network, connect it to the rest
+	for i, network := range container.Config.Networks.Names() {
+		if i == 0 {
+			continue
+		}
+		log.Infof("Connect container %s to network %s", container.Name, network)
+		if err := client.Docker.ConnectNetwork(network, docker.NetworkConnectionOptions{
+			Container:      container.ID,
+			EndpointConfig: container.Config.Networks[network].ToDockerAPI(),
+		}); err != nil {
+			return fmt.Errorf("Failed to connect container to network %s, error: %s", network, err)
+		}
+	}
+
+	if len(container.Config.Secrets) > 0 {
+		if err := client.uploadSecrets(container); err != nil {
+			return err
+		}
+	}
+
 	if container.State.Running || container.Config.State.IsRan() {
 		if client.Attach {
 			if err := client.AttachToContainer(container); err != nil {
@@ -247,6 +347,13 @@ func (client *DockerClient) StartContainer(container *Container) error {
 		if exitCode != 0 {
 			return fmt.Errorf("Container %s exited with code %d", container.Name, exitCode)
 		}
+	} else if gated, err := client.waitReadiness(container); err != nil {
+		if !client.Attach {
+			client.flushContainerLogs(container)
+		}
+		return err
+	} else if gated {
+		return nil
 	} else if client.Wait > 0 {
 		log.Infof("Waiting for %s to ensure %s not exited abnormally...", client.Wait, container.Name)
 		time.Sleep(client.Wait)
@@ -261,6 +368,165 @@ func (client *DockerClient) StartContainer(container *Container) error {
 	return nil
 }
 
+// GetNetworks returns the list of networks of the namespace created by rocker-compose
+func (client *DockerClient) GetNetworks(ns string) ([]*Network, error) {
+	apiNetworks, err := client.Docker.ListNetworks()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list networks, error: %s", err)
+	}
+
+	networks := []*Network{}
+	for _, apiNetwork := range apiNetworks {
+		if _, ok := apiNetwork.Labels["rocker-compose-config"]; !ok {
+			continue
+		}
+		network, err := NewNetworkFromDocker(&apiNetwork)
+		if err != nil {
+			return nil, err
+		}
+		if network.Name.Namespace == ns {
+			networks = append(networks, network)
+		}
+	}
+	sort.Sort(networksByName(networks))
+
+	return networks, nil
+}
+
+// CreateNetwork implements creating a network
+func (client *DockerClient) CreateNetwork(network *Network) error {
+	log.Infof("Create network %s", network.Name)
+
+	opts, err := network.CreateNetworkOptions()
+	if err != nil {
+		return fmt.Errorf("Failed to initialize network options, error: %s", err)
+	}
+	log.Debugf("Creating network with opts: %# v", pretty.Formatter(opts))
+
+	apiNetwork, err := client.Docker.CreateNetwork(*opts)
+	if err != nil {
+		return fmt.Errorf("Failed to create network %s, error: %s", network.Name, err)
+	}
+	network.ID = apiNetwork.ID
+
+	return nil
+}
+
+// RemoveNetwork implements removing a network
+func (client *DockerClient) RemoveNetwork(network *Network) error {
+	log.Infof("Removing network %s", network.Name)
+	if err := client.Docker.RemoveNetwork(network.ID); err != nil {
+		return fmt.Errorf("Failed to remove network %s, error: %s", network.Name, err)
+	}
+	return nil
+}
+
+// RecreateNetwork removes the existing network and creates it with the new spec.
+// Containers connected to the network are disconnected first and connected back
+// to the new one with endpoint settings from their specs. The list of networks
+// does not tell connected containers, so the network is inspected to find them.
+func (client *DockerClient) RecreateNetwork(network, actual *Network) error {
+	info, err := client.Docker.NetworkInfo(actual.ID)
+	if err != nil {
+		return fmt.Errorf("Failed to inspect network %s, error: %s", network.Name, err)
+	}
+
+	connected := []string{}
+	for id := range info.Containers {
+		connected = append(connected, id)
+	}
+	sort.Strings(connected)
+
+	endpoints := map[string]*docker.EndpointConfig{}
+	for _, id := range connected {
+		endpoints[id] = &docker.EndpointConfig{}
+		if inspect, err := client.Docker.InspectContainer(id); err == nil {
+			if container, err := NewContainerFromDocker(inspect); err == nil && container.Config != nil {
+				if endpoint, ok := container.Config.Networks[network.Name.String()]; ok {
+					endpoints[id] = endpoint.ToDockerAPI()
+				}
+			}
+		}
+
+		log.Infof("Disconnect container %.12s from network %s", id, network.Name)
+		if err := client.Docker.DisconnectNetwork(actual.ID, docker.NetworkConnectionOptions{
+			Container: id,
+			Force:     true,
+		}); err != nil {
+			return fmt.Errorf("Failed to disconnect container %.12s from network %s, error: %s", id, network.Name, err)
+		}
+	}
+
+	if err := client.RemoveNetwork(actual); err != nil {
+		return err
+	}
+	if err := client.CreateNetwork(network); err != nil {
+		return err
+	}
+
+	for _, id := range connected {
+		log.Infof("Connect container %.12s to network %s", id, network.Name)
+		if err := client.Docker.ConnectNetwork(network.ID, docker.NetworkConnectionOptions{
+			Container:      id,
+			EndpointConfig: endpoints[id],
+		}); err != nil {
+			return fmt.Errorf("Failed to connect container %.12s to network %s, error: %s", id, network.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// GetVolumes returns the list of volumes of the namespace created by rocker-compose
+func (client *DockerClient) GetVolumes(ns string) ([]*Volume, error) {
+	apiVolumes, err := client.Docker.ListVolumes(docker.ListVolumesOptions{
+		Filters: map[string][]string{
+			"label": []string{"rocker-compose-namespace=" + ns},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list volumes, error: %s", err)
+	}
+
+	volumes := []*Volume{}
+	for _, apiVolume := range apiVolumes {
+		volume, err := NewVolumeFromDocker(&apiVolume)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, volume)
+	}
+	sort.Sort(volumesByName(volumes))
+
+	return volumes, nil
+}
+
+// CreateVolume implements creating a volume
+func (client *DockerClient) CreateVolume(volume *Volume) error {
+	log.Infof("Create volume %s", volume.Name)
+
+	opts, err := volume.CreateVolumeOptions()
+	if err != nil {
+		return fmt.Errorf("Failed to initialize volume options, error: %s", err)
+	}
+	log.Debugf("Creating volume with opts: %# v", pretty.Formatter(opts))
+
+	if _, err := client.Docker.CreateVolume(*opts); err != nil {
+		return fmt.Errorf("Failed to create volume %s, error: %s", volume.Name, err)
+	}
+
+	return nil
+}
+
+// RemoveVolume implements removing a volume
+func (client *DockerClient) RemoveVolume(volume *Volume) error {
+	log.Infof("Removing volume %s", volume.Name)
+	if err := client.Docker.RemoveVolume(volume.Name.String()); err != nil {
+		return fmt.Errorf("Failed to remove volume %s, error: %s", volume.Name, err)
+	}
+	return nil
+}
+
 // EnsureContainerExist implements ensuring that container exists in docker daemon
 func (client *DockerClient) EnsureContainerExist(container *Container) error {
 	log.Infof("Checking container exist %s", container.Name)
@@ -469,6 +735,13 @@ func (client *DockerClient) WaitForContainer(container *Container) (err error) {
 		}
 	}
 
+	// Long-running containers should pass their healthcheck and ready probes
+	if container.Config.State.Bool() && inspect.State.Running {
+		if _, err = client.waitReadiness(container); err != nil {
+			return
+		}
+	}
+
 	if exitCode != 0 {
 		return fmt.Errorf("Container %s exited with code %d", container.Name, exitCode)
 	}
@@ -498,6 +771,99 @@ func (client *DockerClient) Pin(local, hub bool, vars template.Vars, containers
 
 // Internal
 
+// lastHealthCheckOutput formats the output of the last healthcheck probe, if there is any
+func lastHealthCheckOutput(health docker.Health) string {
+	if len(health.Log) == 0 {
+		return ""
+	}
+	last := health.Log[len(health.Log)-1]
+	return fmt.Sprintf(", last probe exited with code %d: %s", last.ExitCode, strings.TrimSpace(last.Output))
+}
+
+// healthTimeout returns how long the container may stay "starting": the start period
+// and the retries of the healthcheck, each taking up to the interval and the timeout,
+// or the --wait duration if it is longer
+func healthTimeout(healthcheck *docker.HealthConfig, wait time.Duration) time.Duration {
+	var (
+		interval    = healthDefaultInterval
+		timeout     = healthDefaultTimeout
+		retries     = healthDefaultRetries
+		startPeriod time.Duration
+	)
+	if healthcheck != nil {
+		if healthcheck.Interval > 0 {
+			interval = healthcheck.Interval
+		}
+		if healthcheck.Timeout > 0 {
+			timeout = healthcheck.Timeout
+		}
+		if healthcheck.Retries > 0 {
+			retries = healthcheck.Retries
+		}
+		startPeriod = healthcheck.StartPeriod
+	}
+
+	limit := startPeriod + time.Duration(retries)*(interval+timeout)
+	if wait > limit {
+		return wait
+	}
+	return limit
+}
+
+// waitHealthy blocks until the container that has a healthcheck reports "healthy".
+// It returns false if the container has no healthcheck, and an error with the output
+// of the last probe if the container became unhealthy, stopped or did not settle
+// in time, see healthTimeout.
+func (client *DockerClient) waitHealthy(container *Container) (bool, error) {
+	var (
+		logged   = false
+		deadline time.Time
+		limit    time.Duration
+	)
+	for {
+		inspect, err := client.Docker.InspectContainer(container.Name.String())
+		if err != nil {
+			return false, err
+		}
+
+		health := inspect.State.Health
+		if health.Status == "" {
+			return false, nil
+		}
+		if deadline.IsZero() {
+			var healthcheck *docker.HealthConfig
+			if inspect.Config != nil {
+				healthcheck = inspect.Config.Healthcheck
+			}
+			limit = healthTimeout(healthcheck, client.Wait)
+			deadline = time.Now().Add(limit)
+		}
+		if !inspect.State.Running {
+			return true, fmt.Errorf("Container %s exited with code %d while waiting to become healthy%s",
+				container.Name, inspect.State.ExitCode, lastHealthCheckOutput(health))
+		}
+
+		switch health.Status {
+		case "healthy":
+			log.Infof("Container %s is healthy", container.Name)
+			return true, nil
+		case "unhealthy":
+			return true, fmt.Errorf("Container %s is unhealthy%s", container.Name, lastHealthCheckOutput(health))
+		}
+
+		if time.Now().After(deadline) {
+			return true, fmt.Errorf("Container %s did not become healthy within %s%s",
+				container.Name, limit, lastHealthCheckOutput(health))
+		}
+
+		if !logged {
+			log.Infof("Waiting for %s to become healthy...", container.Name)
+			logged = true
+		}
+		time.Sleep(healthPollInterval)
+	}
+}
+
 func (client *DockerClient) listenReAttach(containers []*Container) {
 	// The code is partially borrowed from https://github.com/jwilder/docker-gen
 	eventChan := make(chan *docker.APIEvents, 100)
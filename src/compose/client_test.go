@@ -42,6 +42,22 @@ func TestNewClient(t *testing.T) {
 	assert.IsType(t, &DockerClient{}, cli)
 }
 
+func TestClientHealthTimeout(t *testing.T) {
+	// docker defaults: 3 retries of 30s interval and 30s timeout
+	assert.Equal(t, 3*time.Minute, healthTimeout(nil, 0))
+
+	healthcheck := &docker.HealthConfig{
+		Interval:    5 * time.Second,
+		Timeout:     time.Second,
+		Retries:     2,
+		StartPeriod: 10 * time.Second,
+	}
+	assert.Equal(t, 22*time.Second, healthTimeout(healthcheck, time.Second))
+
+	// --wait extends the limit
+	assert.Equal(t, time.Minute, healthTimeout(healthcheck, time.Minute))
+}
+
 func TestClientGetContainers(t *testing.T) {
 	// TODO: mock?
 	t.Skip()
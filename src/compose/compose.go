@@ -24,11 +24,14 @@ import (
 	"fmt"
 	"github.com/grammarly/rocker-compose/src/compose/ansible"
 	"github.com/grammarly/rocker-compose/src/compose/config"
+	"io"
+	"sort"
 	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker/src/imagename"
 	"github.com/grammarly/rocker/src/template"
 	"github.com/kr/pretty"
 )
@@ -36,43 +39,60 @@ import (
 // Config is a configuration object which is passed to compose.New()
 // for creating the new Compose instance.
 type Config struct {
-	Manifest   *config.Config
-	Docker     *docker.Client
-	Force      bool
-	DryRun     bool
-	Attach     bool
-	Pull       bool
-	Remove     bool
-	Recover    bool
-	Wait       time.Duration
-	Auth       *docker.AuthConfigurations
-	KeepImages int
+	Manifest     *config.Config
+	Docker       *docker.Client
+	Force        bool
+	DryRun       bool
+	Attach       bool
+	Pull         bool
+	Remove       bool
+	Recover      bool
+	Wait         time.Duration
+	Auth         *docker.AuthConfigurations
+	KeepImages   int
+	Only         []string
+	BlueGreen    bool
+	Rollback     bool
+	History      *History
+	PruneVolumes bool
 }
 
 // Compose is the main object that executes actions and holds runtime information.
 type Compose struct {
-	Manifest *config.Config
-	DryRun   bool
-	Attach   bool
-	Pull     bool
-	Remove   bool
-	Wait     time.Duration
+	Manifest     *config.Config
+	DryRun       bool
+	Attach       bool
+	Pull         bool
+	Remove       bool
+	Wait         time.Duration
+	Only         []string
+	BlueGreen    bool
+	Rollback     bool
+	History      *History
+	PruneVolumes bool
 
 	client             Client
 	chErrors           chan error
 	attachedContainers map[string]struct{}
 	executionPlan      []Action
+	historyNote        string
+	pinnedImages       map[string]string
 }
 
 // New makes a new Compose object
 func New(config *Config) (*Compose, error) {
 	compose := &Compose{
-		Manifest: config.Manifest,
-		DryRun:   config.DryRun,
-		Attach:   config.Attach,
-		Pull:     config.Pull,
-		Wait:     config.Wait,
-		Remove:   config.Remove,
+		Manifest:     config.Manifest,
+		DryRun:       config.DryRun,
+		Attach:       config.Attach,
+		Pull:         config.Pull,
+		Wait:         config.Wait,
+		Remove:       config.Remove,
+		Only:         config.Only,
+		BlueGreen:    config.BlueGreen,
+		Rollback:     config.Rollback,
+		History:      config.History,
+		PruneVolumes: config.PruneVolumes,
 	}
 
 	cliConf := &DockerClient{
@@ -97,52 +117,31 @@ func New(config *Config) (*Compose, error) {
 
 // RunAction implements 'rocker-compose run'
 func (compose *Compose) RunAction() error {
-	// get the actual list of existing containers from docker client
-	actual, err := compose.client.GetContainers(compose.Manifest.HasExternalRefs())
+	expected, actual, executionPlan, err := compose.buildExecutionPlan()
 	if err != nil {
-		return fmt.Errorf("GetContainers failed with error, error: %s", err)
-	}
-
-	expected := []*Container{}
-
-	// if --remove was specified, pretend we expect to have an empty list of containers
-	if !compose.Remove {
-		expected = GetContainersFromConfig(compose.Manifest)
-	}
-
-	// if --pull is specified PullAll, otherwise Fetch required
-	if compose.Pull {
-		if err := compose.client.PullAll(expected, compose.Manifest.Vars); err != nil {
-			return err
-		}
-	} else if err := compose.client.FetchImages(expected, compose.Manifest.Vars); err != nil {
-		return fmt.Errorf("Failed to fetch images of given containers, error: %s", err)
+		return err
 	}
+	compose.executionPlan = executionPlan
 
-	// Assign IDs of existing containers
-	for _, actualC := range actual {
-		for _, expectedC := range expected {
-			if expectedC.IsSameKind(actualC) {
-				expectedC.ID = actualC.ID
-			}
-		}
+	obsoleteNetworks, err := compose.ensureNetworks(compose.Manifest)
+	if err != nil {
+		return err
 	}
 
-	executionPlan, err := NewDiff(compose.Manifest.Namespace).Diff(expected, actual)
+	obsoleteVolumes, err := compose.ensureVolumes(compose.Manifest)
 	if err != nil {
-		return fmt.Errorf("Diff of configuration failed, error: %s", err)
+		return err
 	}
-	compose.executionPlan = executionPlan
 
-	var runner Runner
-	if compose.DryRun {
-		runner = NewDryRunner()
-	} else {
-		runner = NewDockerClientRunner(compose.client)
+	if err := compose.run(executionPlan, actual); err != nil {
+		return fmt.Errorf("Execution failed with, error: %s", err)
 	}
 
-	if err := runner.Run(executionPlan); err != nil {
-		return fmt.Errorf("Execution failed with, error: %s", err)
+	compose.removeNetworks(obsoleteNetworks)
+	compose.pruneVolumes(obsoleteVolumes)
+
+	if !compose.Remove {
+		compose.recordHistory(compose.Manifest, expected, len(compose.Only) > 0)
 	}
 
 	strContainers := []string{}
@@ -169,6 +168,347 @@ func (compose *Compose) RunAction() error {
 	return nil
 }
 
+// PlanAction implements 'rocker-compose plan'
+// It computes the same execution plan as RunAction does, but instead of running it
+// returns the plan of changes that would be made.
+func (compose *Compose) PlanAction() (*Plan, error) {
+	expected, actual, executionPlan, err := compose.buildExecutionPlan()
+	if err != nil {
+		return nil, err
+	}
+	compose.executionPlan = executionPlan
+
+	plan := NewPlan(compose.Manifest.Namespace, executionPlan, expected, actual)
+	plan.Only = compose.Only
+	plan.Networks = compose.Manifest.Networks
+	plan.Volumes = compose.Manifest.Volumes
+
+	return plan, nil
+}
+
+// ApplyAction implements 'rocker-compose apply'
+// It executes exactly the given plan, which was previously computed by PlanAction.
+// Refuses to run if existing containers or images are not the same as they were
+// at the moment of planning. Values of secrets, which the plan keeps only as hashes,
+// are taken from the Manifest.
+func (compose *Compose) ApplyAction(plan *Plan) error {
+	actual, err := compose.client.GetContainers(plan.HasExternalRefs())
+	if err != nil {
+		return fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	if err := plan.Verify(actual); err != nil {
+		return fmt.Errorf("The plan is stale, make a new one, error: %s", err)
+	}
+
+	if plan.HasHashedSecrets() {
+		if compose.Manifest == nil {
+			return fmt.Errorf("The plan has secrets, which are not saved in it, the manifest it was made from is needed to apply it")
+		}
+		if err := plan.ResolveSecrets(compose.Manifest); err != nil {
+			return fmt.Errorf("The plan is stale, make a new one, error: %s", err)
+		}
+	}
+
+	// remember image ids the plan was made for, fetching will override them
+	imageIDs := map[*Container]string{}
+	for _, container := range plan.expected {
+		imageIDs[container] = container.ImageID
+	}
+
+	if err := compose.client.FetchImages(plan.expected, template.Vars{}); err != nil {
+		return fmt.Errorf("Failed to fetch images of given containers, error: %s", err)
+	}
+
+	for container, imageID := range imageIDs {
+		if imageID != "" && container.ImageID != imageID {
+			return fmt.Errorf("The plan is stale, make a new one, error: image %s of container %s is %s, but the plan was made for %s",
+				container.Image, container.Name, container.ImageID, imageID)
+		}
+	}
+
+	compose.executionPlan = plan.actions
+	compose.Only = plan.Only
+
+	// networks and volumes are ensured from the specs saved in the plan, the same way as by 'run'
+	obsoleteNetworks, err := compose.ensureNetworks(plan.manifest())
+	if err != nil {
+		return err
+	}
+
+	obsoleteVolumes, err := compose.ensureVolumes(plan.manifest())
+	if err != nil {
+		return err
+	}
+
+	if err := compose.run(plan.actions, plan.actual); err != nil {
+		return fmt.Errorf("Execution failed with, error: %s", err)
+	}
+
+	compose.removeNetworks(obsoleteNetworks)
+	compose.pruneVolumes(obsoleteVolumes)
+
+	compose.recordHistory(plan.manifest(), plan.expected, len(plan.Only) > 0)
+
+	log.Infof("OK, plan is applied: %d to create, %d to update, %d to recreate, %d to remove",
+		plan.Count(ChangeCreate), plan.Count(ChangeUpdate), plan.Count(ChangeRecreate), plan.Count(ChangeRemove))
+
+	return nil
+}
+
+// StatusAction implements 'rocker-compose status'
+// It compares containers of the manifest with existing ones without changing anything.
+// All containers are inspected, so those not created by rocker-compose are reported as well.
+func (compose *Compose) StatusAction() (*Status, error) {
+	actual, err := compose.client.GetContainers(true)
+	if err != nil {
+		return nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	expected := GetContainersFromConfig(compose.Manifest)
+
+	return NewStatus(compose.Manifest.Namespace, expected, actual), nil
+}
+
+// RollbackAction implements 'rocker-compose rollback'
+// It runs the manifest of the given revision as usual, so only containers
+// that differ from the revision are changed. Containers run the same images
+// they were running at the revision, and secrets are taken from the Manifest.
+func (compose *Compose) RollbackAction(rev *Revision) error {
+	log.Infof("Rolling back namespace %s to revision %d made at %s", rev.Namespace, rev.Number, rev.Timestamp.Format(time.RFC3339))
+
+	manifest, err := rev.Config(compose.Manifest)
+	if err != nil {
+		return fmt.Errorf("Cannot roll back to revision %d, error: %s", rev.Number, err)
+	}
+
+	compose.Manifest = manifest
+	compose.Only = nil
+	compose.historyNote = fmt.Sprintf("rollback to revision %d", rev.Number)
+	compose.pinnedImages = rev.ImageIDs()
+
+	return compose.RunAction()
+}
+
+// recordHistory saves the revision of the namespace of the manifest with given containers
+// and networks and volumes of the manifest if history is enabled and they differ from the last
+// revision. If partial is true, i.e. only some of the containers were run, the rest are taken
+// from the previous revision. Failures are not fatal, since changes are already made.
+func (compose *Compose) recordHistory(manifest *config.Config, expected []*Container, partial bool) {
+	if compose.History == nil || compose.DryRun {
+		return
+	}
+
+	ns := manifest.Namespace
+	rev := NewRevision(ns, expected)
+	rev.Description = compose.historyNote
+	rev.Networks = manifest.Networks
+	rev.Volumes = manifest.Volumes
+
+	last, err := compose.History.Last(ns)
+	if err != nil {
+		log.Warnf("Failed to record history, error: %s", err)
+		return
+	}
+
+	// do not record idempotent runs, otherwise rollback would go back to the same state
+	if last != nil && last.Matches(manifest, expected, partial) {
+		log.Infof("Namespace %s is the same as revision %d, nothing to record", ns, last.Number)
+		return
+	}
+
+	if partial && last != nil {
+		rev.Merge(last)
+	}
+
+	if err := compose.History.Save(rev); err != nil {
+		log.Warnf("Failed to record history, error: %s", err)
+		return
+	}
+
+	log.Infof("Recorded revision %d of namespace %s", rev.Number, ns)
+}
+
+// pinImages makes containers run exactly the images they were running at the revision
+// being rolled back to, since their tags may point to other images by now
+func (compose *Compose) pinImages(containers []*Container) error {
+	pinned := []*Container{}
+	for _, container := range containers {
+		imageID, ok := compose.pinnedImages[container.Name.Name]
+		if !ok || container.ImageID == imageID {
+			continue
+		}
+		log.Warnf("Image %s of container %s is %.19s now, pin it to %.19s of the revision",
+			container.Image, container.Name, container.ImageID, imageID)
+		container.Image = imagename.NewFromString(imageID)
+		pinned = append(pinned, container)
+	}
+
+	if len(pinned) == 0 {
+		return nil
+	}
+	if err := compose.client.FetchImages(pinned, compose.Manifest.Vars); err != nil {
+		return fmt.Errorf("Failed to find images of the revision, error: %s", err)
+	}
+	return nil
+}
+
+// run executes the actions, or only prints them in dry mode. If rollback is enabled
+// and execution fails, existing containers removed so far are restored.
+func (compose *Compose) run(actions []Action, actual []*Container) error {
+	if compose.DryRun {
+		return NewDryRunner().Run(actions)
+	}
+	if !compose.Rollback {
+		return NewDockerClientRunner(compose.client).Run(actions)
+	}
+
+	recorder := newRemovalRecorder(compose.client, actual)
+	specs := compose.rollbackSpecs(removedContainers(actions))
+
+	err := NewDockerClientRunner(recorder).Run(actions)
+	if err == nil {
+		return nil
+	}
+
+	log.Errorf("Execution failed, rolling back removed containers, error: %s", err)
+
+	restored, rollbackErr := compose.rollback(recorder.Removed(), specs)
+
+	names := []string{}
+	for _, container := range restored {
+		names = append(names, container.Name.String())
+	}
+	if len(names) > 0 {
+		log.Infof("Restored containers: %s", strings.Join(names, ", "))
+	} else if rollbackErr == nil {
+		log.Infof("Nothing to restore, no existing containers were removed")
+	}
+
+	if rollbackErr != nil {
+		return fmt.Errorf("%s; rollback failed, error: %s", err, rollbackErr)
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("%s; rolled back, restored containers: %s", err, strings.Join(names, ", "))
+	}
+	return err
+}
+
+// ensureNetworks creates networks of the manifest that do not exist and recreates
+// those which spec has changed. Returns existing networks of the namespace that are
+// not in the manifest anymore, they should be removed after containers.
+func (compose *Compose) ensureNetworks(manifest *config.Config) (obsolete []*Network, err error) {
+	actual, err := compose.client.GetNetworks(manifest.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := GetNetworksFromConfig(manifest)
+	if compose.Remove {
+		expected = []*Network{}
+	}
+
+	create, recreate, remove := diffNetworks(expected, actual)
+
+	for _, network := range create {
+		if compose.DryRun {
+			log.Infof("[DRY] Create network %s", network.Name)
+			continue
+		}
+		if err := compose.client.CreateNetwork(network); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, network := range recreate {
+		if compose.DryRun {
+			log.Infof("[DRY] Recreate network %s", network.Name)
+			continue
+		}
+		if err := compose.client.RecreateNetwork(network, findNetwork(actual, network.Name)); err != nil {
+			return nil, err
+		}
+	}
+
+	// networks are not removed if only some of the containers are run
+	if len(compose.Only) > 0 {
+		return nil, nil
+	}
+
+	return remove, nil
+}
+
+// removeNetworks removes the given networks. Failures are not fatal,
+// e.g. containers of other namespaces may still be connected to the network.
+func (compose *Compose) removeNetworks(networks []*Network) {
+	for _, network := range networks {
+		if compose.DryRun {
+			log.Infof("[DRY] Remove network %s", network.Name)
+			continue
+		}
+		if err := compose.client.RemoveNetwork(network); err != nil {
+			log.Warnf("%s", err)
+		}
+	}
+}
+
+// ensureVolumes creates volumes of the manifest that do not exist. Volumes
+// which spec has changed are never recreated to keep the data. Returns existing
+// volumes of the namespace that are not in the manifest anymore.
+func (compose *Compose) ensureVolumes(manifest *config.Config) (obsolete []*Volume, err error) {
+	actual, err := compose.client.GetVolumes(manifest.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := GetVolumesFromConfig(manifest)
+	if compose.Remove {
+		expected = []*Volume{}
+	}
+
+	create, changed, obsolete := diffVolumes(expected, actual)
+
+	for _, volume := range create {
+		if compose.DryRun {
+			log.Infof("[DRY] Create volume %s", volume.Name)
+			continue
+		}
+		if err := compose.client.CreateVolume(volume); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, volume := range changed {
+		log.Warnf("Spec of volume %s has changed, it is not recreated to keep the data; remove it manually to apply", volume.Name)
+	}
+
+	// volumes are not removed if only some of the containers are run
+	if len(compose.Only) > 0 {
+		return nil, nil
+	}
+
+	return obsolete, nil
+}
+
+// pruneVolumes removes the given volumes if --prune-volumes was specified,
+// otherwise just tells about them. Failures are not fatal, e.g. the volume
+// may still be used by containers of other namespaces.
+func (compose *Compose) pruneVolumes(volumes []*Volume) {
+	for _, volume := range volumes {
+		if !compose.PruneVolumes {
+			log.Infof("Volume %s is not in the manifest, use --prune-volumes to remove it", volume.Name)
+			continue
+		}
+		if compose.DryRun {
+			log.Infof("[DRY] Remove volume %s", volume.Name)
+			continue
+		}
+		if err := compose.client.RemoveVolume(volume); err != nil {
+			log.Warnf("%s", err)
+		}
+	}
+}
+
 // RecoverAction implements 'rocker-compose recover'
 //
 // TODO: It duplicates the code of RunAction a bit. Also, do we need this function at all?
@@ -182,11 +522,18 @@ func (compose *Compose) RecoverAction() error {
 	// collect expected containers list based on actual state
 	// but use expected state
 	expected := []*Container{}
+	unknown := map[*Container]error{}
 	for _, c := range actual {
 		expectedC := *c // actually copy the struct
 		expectedC.State = &ContainerState{
 			Running: c.Config.State.Bool(),
 		}
+		spec, err := c.specWithSecrets(compose.Manifest)
+		if err != nil {
+			// good enough to compare, but not to create the container again
+			spec, unknown[&expectedC] = c.Config, err
+		}
+		expectedC.Config = spec
 		expected = append(expected, &expectedC)
 	}
 
@@ -194,6 +541,24 @@ func (compose *Compose) RecoverAction() error {
 	if err != nil {
 		return fmt.Errorf("Diff of configuration failed, error: %s", err)
 	}
+
+	// contents of secrets are not kept on the docker host, so containers that should be
+	// created again, but whose secrets are not in the manifest, are left as they are
+	skipped := map[string]struct{}{}
+	WalkActions(executionPlan, func(action Action) {
+		container := createdContainer(action)
+		if err, ok := unknown[container]; ok {
+			log.Errorf("Cannot recover container %s, error: %s", container.Name, err)
+			skipped[container.Name.String()] = struct{}{}
+		}
+	})
+
+	if len(skipped) > 0 {
+		expected, actual = withoutContainers(expected, skipped), withoutContainers(actual, skipped)
+		if executionPlan, err = NewDiff("").Diff(expected, actual); err != nil {
+			return fmt.Errorf("Diff of configuration failed, error: %s", err)
+		}
+	}
 	compose.executionPlan = executionPlan
 
 	var runner Runner
@@ -220,6 +585,15 @@ func (compose *Compose) RecoverAction() error {
 		log.Infof("Nothing is running")
 	}
 
+	if len(skipped) > 0 {
+		names := []string{}
+		for name := range skipped {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("Containers were not recovered, contents of their secrets are unknown: %s", strings.Join(names, ", "))
+	}
+
 	return nil
 }
 
@@ -258,12 +632,121 @@ func (compose *Compose) PinAction(local, hub bool) (template.Vars, error) {
 	return vars, nil
 }
 
+// BackupAction implements 'rocker-compose backup'
+func (compose *Compose) BackupAction(name string, w io.Writer) error {
+	container, err := compose.findContainer(name)
+	if err != nil {
+		return err
+	}
+	return compose.client.BackupVolumes(container, w)
+}
+
+// RestoreAction implements 'rocker-compose restore'
+func (compose *Compose) RestoreAction(name string, r io.Reader) error {
+	container, err := compose.findContainer(name)
+	if err != nil {
+		return err
+	}
+	return compose.client.RestoreVolumes(container, r)
+}
+
+// findContainer finds the existing container by name; names without
+// the namespace refer to containers of the manifest namespace
+func (compose *Compose) findContainer(name string) (*Container, error) {
+	actual, err := compose.client.GetContainers(false)
+	if err != nil {
+		return nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	containerName := config.NewContainerNameFromString(name)
+	if containerName.Namespace == "" {
+		containerName.Namespace = compose.Manifest.Namespace
+	}
+
+	container := find(actual, containerName)
+	if container == nil {
+		return nil, fmt.Errorf("Container %s does not exist", containerName)
+	}
+
+	return container, nil
+}
+
+// buildExecutionPlan gets the list of existing containers, fetches images for the expected
+// ones and returns the list of actions that is needed to transition to the expected state.
+func (compose *Compose) buildExecutionPlan() (expected, actual []*Container, executionPlan []Action, err error) {
+	// get the actual list of existing containers from docker client
+	actual, err = compose.client.GetContainers(compose.Manifest.HasExternalRefs())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	expected = GetContainersFromConfig(compose.Manifest)
+
+	// if particular containers were given, narrow down both lists to them and their
+	// dependencies, so other containers of the namespace are neither touched nor removed
+	if len(compose.Only) > 0 {
+		ns := compose.Manifest.Namespace
+
+		if expected, err = SelectContainers(ns, compose.Only, expected, actual); err != nil {
+			return nil, nil, nil, err
+		}
+
+		selectedActual := []*Container{}
+		for _, container := range actual {
+			if container.Name.Namespace != ns || find(expected, container.Name) != nil {
+				selectedActual = append(selectedActual, container)
+			}
+		}
+		actual = selectedActual
+	}
+
+	// if --remove was specified, pretend we expect to have an empty list of containers
+	if compose.Remove {
+		expected = []*Container{}
+	}
+
+	// if --pull is specified PullAll, otherwise Fetch required
+	if compose.Pull {
+		if err := compose.client.PullAll(expected, compose.Manifest.Vars); err != nil {
+			return nil, nil, nil, err
+		}
+	} else if err := compose.client.FetchImages(expected, compose.Manifest.Vars); err != nil {
+		return nil, nil, nil, fmt.Errorf("Failed to fetch images of given containers, error: %s", err)
+	}
+
+	if err := compose.pinImages(expected); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Assign IDs of existing containers
+	for _, actualC := range actual {
+		for _, expectedC := range expected {
+			if expectedC.IsSameKind(actualC) {
+				expectedC.ID = actualC.ID
+			}
+		}
+	}
+
+	diff := NewDiff(compose.Manifest.Namespace)
+	if compose.BlueGreen {
+		diff = NewBlueGreenDiff(compose.Manifest.Namespace)
+	}
+
+	executionPlan, err = diff.Diff(expected, actual)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Diff of configuration failed, error: %s", err)
+	}
+
+	return expected, actual, executionPlan, nil
+}
+
 // WritePlan saves various rocker-compose change information to the ansible.Response object
 // TODO: should compose know about ansible.Response at all?
 //       maybe it should give some data struct back to main?
 func (compose *Compose) WritePlan(resp *ansible.Response) *ansible.Response {
 	resp.Removed = []ansible.ResponseContainer{}
 	resp.Created = []ansible.ResponseContainer{}
+	resp.Updated = []ansible.ResponseContainer{}
 	resp.Pulled = []string{}
 	resp.Cleaned = []string{}
 
@@ -280,6 +763,32 @@ func (compose *Compose) WritePlan(resp *ansible.Response) *ansible.Response {
 				Name: a.container.Name.String(),
 			})
 		}
+		if a, ok := action.(*replaceContainer); ok {
+			resp.Removed = append(resp.Removed, ansible.ResponseContainer{
+				ID:   a.actual.ID,
+				Name: a.actual.Name.String(),
+			})
+			resp.Created = append(resp.Created, ansible.ResponseContainer{
+				ID:   a.container.ID,
+				Name: a.container.Name.String(),
+			})
+		}
+		if a, ok := action.(*migrateContainer); ok {
+			resp.Removed = append(resp.Removed, ansible.ResponseContainer{
+				ID:   a.actual.ID,
+				Name: a.actual.Name.String(),
+			})
+			resp.Created = append(resp.Created, ansible.ResponseContainer{
+				ID:   a.container.ID,
+				Name: a.container.Name.String(),
+			})
+		}
+		if a, ok := action.(*updateContainer); ok {
+			resp.Updated = append(resp.Updated, ansible.ResponseContainer{
+				ID:   a.actual.ID,
+				Name: a.actual.Name.String(),
+			})
+		}
 	})
 
 	// TODO: images are pulled but may not be changed
@@ -291,6 +800,6 @@ func (compose *Compose) WritePlan(resp *ansible.Response) *ansible.Response {
 		resp.Cleaned = append(resp.Cleaned, imageName.String())
 	}
 
-	resp.Changed = len(resp.Removed)+len(resp.Created)+len(resp.Pulled) > 0
+	resp.Changed = len(resp.Removed)+len(resp.Created)+len(resp.Updated)+len(resp.Pulled) > 0
 	return resp
 }
@@ -19,6 +19,7 @@ package config
 import (
 	"reflect"
 	"sort"
+	"strings"
 
 	"github.com/go-yaml/yaml"
 )
@@ -29,11 +30,13 @@ func (a *Container) LastCompareField() string {
 }
 
 // IsEqualTo compares the container spec against another one.
-// It returns false if at least one property is unequal.
+// It returns false if at least one property is unequal. Values of secret
+// env vars are compared by their hashes.
 func (a *Container) IsEqualTo(b *Container) bool {
+	ah, bh := hashSecretsPair(a, b)
 	for _, field := range getComparableFields() {
 		a.lastCompareField = field
-		if equal, _ := compareYaml(field, a, b); !equal {
+		if equal, _ := compareYaml(field, ah, bh); !equal {
 			// TODO: return err
 			return false
 		}
@@ -42,6 +45,65 @@ func (a *Container) IsEqualTo(b *Container) bool {
 	return true
 }
 
+// FieldDiff describes a single property of the container spec that differs
+// between two specs. Values are given in their YAML representation.
+type FieldDiff struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Diff compares the container spec against another one and returns the list
+// of all unequal properties. Old values are taken from the given spec 'b'
+// and new values from the current one. Values of secret env vars are
+// compared and reported by their hashes.
+func (a *Container) Diff(b *Container) (diffs []FieldDiff, err error) {
+	a, b = hashSecretsPair(a, b)
+	for _, field := range getComparableFields() {
+		yml1, yml2, err := marshalCompareValues(field, a, b)
+		if err != nil {
+			return nil, err
+		}
+		if yml1 != yml2 {
+			diffs = append(diffs, FieldDiff{
+				Field: getYamlFieldName(field),
+				Old:   strings.TrimSpace(yml2),
+				New:   strings.TrimSpace(yml1),
+			})
+		}
+	}
+	return diffs, nil
+}
+
+// CanUpdateLive returns true if every given difference can be applied to the
+// existing container with 'docker update' instead of recreating it: memory,
+// memory_swap, cpu_shares, cpuset_cpus and restart. Docker ignores zero values
+// on update, so these limits can be changed this way but cannot be unset.
+func (a *Container) CanUpdateLive(diffs []FieldDiff) bool {
+	if len(diffs) == 0 {
+		return false
+	}
+	for _, d := range diffs {
+		var ok bool
+		switch d.Field {
+		case "memory":
+			ok = a.Memory.Int64() > 0
+		case "memory_swap":
+			ok = a.MemorySwap.Int64() != 0
+		case "cpu_shares":
+			ok = a.CPUShares != nil && *a.CPUShares > 0
+		case "cpuset_cpus":
+			ok = a.CpusetCpus != nil && *a.CpusetCpus != ""
+		case "restart":
+			ok = true
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // IsEqualTo compares the ContainerName against another one.
 // namespace and name should be same.
 func (a *ContainerName) IsEqualTo(b *ContainerName) bool {
@@ -58,6 +120,16 @@ func (a *ContainerName) IsEqualNs(b *ContainerName) bool {
 // TODO: here would be nice to say few words about our approach of container specs comparison.
 
 func compareYaml(name string, a, b *Container) (bool, error) {
+	yml1, yml2, err := marshalCompareValues(name, a, b)
+	if err != nil {
+		return false, err
+	}
+	return yml1 == yml2, nil
+}
+
+// marshalCompareValues returns normalized YAML representations of the given
+// property of both container specs, which are suitable for comparison
+func marshalCompareValues(name string, a, b *Container) (string, string, error) {
 	av := reflect.Indirect(reflect.ValueOf(a)).FieldByName(name)
 	bv := reflect.Indirect(reflect.ValueOf(b)).FieldByName(name)
 
@@ -85,14 +157,14 @@ func compareYaml(name string, a, b *Container) (bool, error) {
 
 	yml1, err := yaml.Marshal(av.Interface())
 	if err != nil {
-		return false, err
+		return "", "", err
 	}
 	yml2, err := yaml.Marshal(bv.Interface())
 	if err != nil {
-		return false, err
+		return "", "", err
 	}
 
-	return string(yml1) == string(yml2), nil
+	return string(yml1), string(yml2), nil
 }
 
 type yamlSortable []interface{}
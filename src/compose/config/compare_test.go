@@ -142,7 +142,7 @@ func TestConfigIsEqualTo(t *testing.T) {
 		},
 		// type: []string
 		fieldSpec{
-			[]string{"DNS", "AddHost", "Expose", "Volumes", "VolumesFrom", "Links", "WaitFor", "Ports"},
+			[]string{"DNS", "AddHost", "Expose", "Volumes", "VolumesFrom", "Links", "WaitFor", "Ports", "SecretEnv"},
 			[]check{
 				check{shouldEqual, "", ""},
 				check{shouldEqual, "KEY:\n  - foo", "KEY:\n  - foo"},
@@ -194,6 +194,36 @@ func TestConfigIsEqualTo(t *testing.T) {
 				check{shouldNotEqual, "KEY:\n  - name: nofile\n    soft: 1024\n    hard: 2048\n  - name: /app\n    soft: 1024\n    hard: 2048", ""},
 			},
 		},
+		// type: Networks
+		fieldSpec{
+			[]string{"Networks"},
+			[]check{
+				check{shouldEqual, "", ""},
+				check{shouldEqual, "KEY: backend", "KEY: [backend]"},
+				check{shouldEqual, "KEY: [backend, frontend]", "KEY:\n  frontend:\n  backend:"},
+				check{shouldEqual, "KEY:\n  backend:\n    aliases: [api]", "KEY:\n  backend:\n    aliases: api"},
+				check{shouldNotEqual, "KEY: backend", ""},
+				check{shouldNotEqual, "", "KEY: backend"},
+				check{shouldNotEqual, "KEY: backend", "KEY: frontend"},
+				check{shouldNotEqual, "KEY: [backend, frontend]", "KEY: backend"},
+				check{shouldNotEqual, "KEY: backend", "KEY:\n  backend:\n    aliases: [api]"},
+				check{shouldNotEqual, "KEY:\n  backend:\n    ipv4_address: 172.28.0.10", "KEY:\n  backend:\n    ipv4_address: 172.28.0.11"},
+			},
+		},
+		// type: *Healthcheck
+		fieldSpec{
+			[]string{"Healthcheck"},
+			[]check{
+				check{shouldEqual, "", ""},
+				check{shouldEqual, "KEY:\n  test: curl -f localhost", "KEY:\n  test: [CMD-SHELL, curl -f localhost]"},
+				check{shouldEqual, "KEY:\n  test: [curl, -f, localhost]\n  interval: 5s", "KEY:\n  interval: 5000ms\n  test: [CMD, curl, -f, localhost]"},
+				check{shouldNotEqual, "KEY:\n  test: curl -f localhost", ""},
+				check{shouldNotEqual, "", "KEY:\n  test: curl -f localhost"},
+				check{shouldNotEqual, "KEY:\n  test: curl -f localhost", "KEY:\n  test: [curl, -f, localhost]"},
+				check{shouldNotEqual, "KEY:\n  test: curl -f localhost\n  retries: 3", "KEY:\n  test: curl -f localhost\n  retries: 5"},
+				check{shouldNotEqual, "KEY:\n  test: curl -f localhost\n  timeout: 1s", "KEY:\n  test: curl -f localhost"},
+			},
+		},
 		// type: map[string]string
 		fieldSpec{
 			[]string{"Labels", "Env", "Extra", "LogOpt"},
@@ -211,6 +241,21 @@ func TestConfigIsEqualTo(t *testing.T) {
 				check{shouldNotEqual, "KEY:\n  xxx: yyy", "KEY:\n  foo: bar\n  xxx: yyy"},
 			},
 		},
+		// type: Secrets
+		fieldSpec{
+			[]string{"Secrets"},
+			[]check{
+				check{shouldEqual, "", ""},
+				check{shouldEqual, "KEY:\n  db:\n    value: foo", "KEY:\n  db:\n    value: foo"},
+				check{shouldEqual, "KEY:\n  db:\n    value: foo\n    file: /a", "KEY:\n  db:\n    value: foo\n    file: /b"},
+				check{shouldNotEqual, "KEY:\n  db:\n    value: foo", ""},
+				check{shouldNotEqual, "", "KEY:\n  db:\n    value: foo"},
+				check{shouldNotEqual, "KEY:\n  db:\n    value: foo", "KEY:\n  db:\n    value: bar"},
+				check{shouldNotEqual, "KEY:\n  db:\n    value: foo", "KEY:\n  api:\n    value: foo"},
+				check{shouldNotEqual, "KEY:\n  db:\n    value: foo", "KEY:\n  db:\n    value: foo\n    target: /db"},
+				check{shouldNotEqual, "KEY:\n  db:\n    value: foo", "KEY:\n  db:\n    value: foo\n    mode: 0400"},
+			},
+		},
 	}
 
 	for _, spec := range cases {
@@ -263,3 +308,72 @@ func TestConfigIsEqualTo(t *testing.T) {
 		assert.True(t, found, fmt.Sprintf("missing compare check for field: %s", fieldName))
 	}
 }
+
+func TestConfigDiff(t *testing.T) {
+	var (
+		shares1 int64 = 512
+		shares2 int64 = 1024
+	)
+	c1 := &Container{
+		CPUShares: &shares1,
+		Env:       StringMap{"FOO": "bar"},
+		Cmd:       Cmd{"echo", "1"},
+	}
+	c2 := &Container{
+		CPUShares: &shares2,
+		Env:       StringMap{"FOO": "baz"},
+		Cmd:       Cmd{"echo", "1"},
+	}
+
+	diffs, err := c1.Diff(c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []FieldDiff{
+		{Field: "cpu_shares", Old: "1024", New: "512"},
+		{Field: "env", Old: "FOO: baz", New: "FOO: bar"},
+	}, diffs)
+
+	diffs, err = c1.Diff(c1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, diffs)
+}
+
+func TestConfigCanUpdateLive(t *testing.T) {
+	var (
+		shares int64 = 512
+		cpuset       = "0-2"
+	)
+	c1 := &Container{
+		CPUShares:  &shares,
+		CpusetCpus: &cpuset,
+		Memory:     NewConfigMemoryFromInt64(300 * 1024 * 1024),
+		Restart:    &RestartPolicy{"on-failure", 3},
+	}
+	c2 := &Container{}
+
+	diffs, err := c1.Diff(c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, c1.CanUpdateLive(diffs))
+
+	// limits cannot be unset by 'docker update'
+	diffs, err = c2.Diff(c1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, c2.CanUpdateLive(diffs))
+
+	c3 := &Container{Memory: c1.Memory, Env: StringMap{"FOO": "bar"}}
+	diffs, err = c3.Diff(c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, c3.CanUpdateLive(diffs))
+
+	assert.False(t, c1.CanUpdateLive(nil))
+}
@@ -26,12 +26,15 @@ package config
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/grammarly/rocker/src/imagename"
 	"github.com/grammarly/rocker/src/template"
@@ -45,47 +48,74 @@ import (
 type Config struct {
 	Namespace  string // All containers names under current compose.yml will be prefixed with this namespace
 	Containers map[string]*Container
+	Networks   map[string]*Network // user-defined networks, named the same way as containers
+	Volumes    map[string]*Volume  // named volumes, named the same way as containers
 	Vars       template.Vars
 }
 
+// Network represents a user-defined docker network spec from compose.yml
+type Network struct {
+	Driver     *string   `yaml:"driver,omitempty"`
+	DriverOpts StringMap `yaml:"driver_opts,omitempty"`
+	Subnet     *string   `yaml:"subnet,omitempty"`
+	Gateway    *string   `yaml:"gateway,omitempty"`
+	Internal   *bool     `yaml:"internal,omitempty"`
+	Labels     StringMap `yaml:"labels,omitempty"`
+}
+
+// Volume represents a named docker volume spec from compose.yml
+type Volume struct {
+	Driver     *string   `yaml:"driver,omitempty"`
+	DriverOpts StringMap `yaml:"driver_opts,omitempty"`
+	Labels     StringMap `yaml:"labels,omitempty"`
+}
+
 // Container represents a single container spec from compose.yml
 type Container struct {
-	Extends         string         `yaml:"extends,omitempty"`           // can extend from other container spec referring by name
-	Image           *string        `yaml:"image,omitempty"`             //
-	Net             *Net           `yaml:"net,omitempty"`               //
-	Pid             *string        `yaml:"pid,omitempty"`               //
-	Uts             *string        `yaml:"uts,omitempty"`               //
-	State           *State         `yaml:"state,omitempty"`             // "running" or "created" or "ran"
-	DNS             Strings        `yaml:"dns,omitempty"`               //
-	AddHost         Strings        `yaml:"add_host,omitempty"`          //
-	Restart         *RestartPolicy `yaml:"restart,omitempty"`           //
-	Memory          *Memory        `yaml:"memory,omitempty"`            //
-	MemorySwap      *Memory        `yaml:"memory_swap,omitempty"`       //
-	CPUShares       *int64         `yaml:"cpu_shares,omitempty"`        //
-	CpusetCpus      *string        `yaml:"cpuset_cpus,omitempty"`       //
-	OomKillDisable  *bool          `yaml:"oom_kill_disable,omitempty"`  // e.g. docker run --oom-kill-disable TODO: pull request to go-dockerclient
-	Ulimits         []Ulimit       `yaml:"ulimits,omitempty"`           // search by "Ulimits" here https://goo.gl/IxbZck
-	Privileged      *bool          `yaml:"privileged,omitempty"`        //
-	Cmd             Cmd            `yaml:"cmd,omitempty"`               //
-	Entrypoint      Strings        `yaml:"entrypoint,omitempty"`        //
-	Expose          Strings        `yaml:"expose,omitempty"`            //
-	Ports           Ports          `yaml:"ports,omitempty"`             //
-	LogDriver       *string        `yaml:"log_driver,omitempty"`        //
-	LogOpt          StringMap      `yaml:"log_opt,omitempty"`           //
-	PublishAllPorts *bool          `yaml:"publish_all_ports,omitempty"` //
-	Labels          StringMap      `yaml:"labels,omitempty"`            //
-	Env             StringMap      `yaml:"env,omitempty"`               //
-	VolumesFrom     ContainerNames `yaml:"volumes_from,omitempty"`      //
-	Volumes         Strings        `yaml:"volumes,omitempty"`           //
-	Links           Links          `yaml:"links,omitempty"`             //
-	WaitFor         ContainerNames `yaml:"wait_for,omitempty"`          //
-	KillTimeout     *uint          `yaml:"kill_timeout,omitempty"`      //
-	Hostname        *string        `yaml:"hostname,omitempty"`          //
-	Domainname      *string        `yaml:"domainname,omitempty"`        //
-	User            *string        `yaml:"user,omitempty"`              //
-	Workdir         *string        `yaml:"workdir,omitempty"`           //
-	NetworkDisabled *bool          `yaml:"network_disabled,omitempty"`  // TODO: do we need this?
-	KeepVolumes     *bool          `yaml:"keep_volumes,omitempty"`      //
+	Extends           *Extends       `yaml:"extends,omitempty"`            // can extend from other container spec referring by name
+	Image             *string        `yaml:"image,omitempty"`              //
+	Net               *Net           `yaml:"net,omitempty"`                //
+	Pid               *string        `yaml:"pid,omitempty"`                //
+	Uts               *string        `yaml:"uts,omitempty"`                //
+	State             *State         `yaml:"state,omitempty"`              // "running" or "created" or "ran"
+	DNS               Strings        `yaml:"dns,omitempty"`                //
+	AddHost           Strings        `yaml:"add_host,omitempty"`           //
+	Restart           *RestartPolicy `yaml:"restart,omitempty"`            //
+	Memory            *Memory        `yaml:"memory,omitempty"`             //
+	MemorySwap        *Memory        `yaml:"memory_swap,omitempty"`        //
+	CPUShares         *int64         `yaml:"cpu_shares,omitempty"`         //
+	CpusetCpus        *string        `yaml:"cpuset_cpus,omitempty"`        //
+	OomKillDisable    *bool          `yaml:"oom_kill_disable,omitempty"`   // e.g. docker run --oom-kill-disable TODO: pull request to go-dockerclient
+	Ulimits           []Ulimit       `yaml:"ulimits,omitempty"`            // search by "Ulimits" here https://goo.gl/IxbZck
+	Privileged        *bool          `yaml:"privileged,omitempty"`         //
+	Cmd               Cmd            `yaml:"cmd,omitempty"`                //
+	Entrypoint        Strings        `yaml:"entrypoint,omitempty"`         //
+	Expose            Strings        `yaml:"expose,omitempty"`             //
+	Ports             Ports          `yaml:"ports,omitempty"`              //
+	LogDriver         *string        `yaml:"log_driver,omitempty"`         //
+	LogOpt            StringMap      `yaml:"log_opt,omitempty"`            //
+	PublishAllPorts   *bool          `yaml:"publish_all_ports,omitempty"`  //
+	Labels            StringMap      `yaml:"labels,omitempty"`             //
+	Env               StringMap      `yaml:"env,omitempty"`                //
+	SecretEnv         Strings        `yaml:"secret_env,omitempty"`         // env vars or patterns whose values are stored hashed in the container label
+	Secrets           Secrets        `yaml:"secrets,omitempty"`            // files put into the container before it starts
+	EnvFile           Strings        `yaml:"env_file,omitempty"`           // files of KEY=VALUE lines merged under env, relative to the manifest
+	VolumesFrom       ContainerNames `yaml:"volumes_from,omitempty"`       //
+	Volumes           Strings        `yaml:"volumes,omitempty"`            //
+	Links             Links          `yaml:"links,omitempty"`              //
+	WaitFor           ContainerNames `yaml:"wait_for,omitempty"`           //
+	KillTimeout       *uint          `yaml:"kill_timeout,omitempty"`       //
+	Hostname          *string        `yaml:"hostname,omitempty"`           //
+	Domainname        *string        `yaml:"domainname,omitempty"`         //
+	User              *string        `yaml:"user,omitempty"`               //
+	Workdir           *string        `yaml:"workdir,omitempty"`            //
+	NetworkDisabled   *bool          `yaml:"network_disabled,omitempty"`   // TODO: do we need this?
+	KeepVolumes       *bool          `yaml:"keep_volumes,omitempty"`       //
+	MigrateVolumes    *bool          `yaml:"migrate_volumes,omitempty"`    // copy data of volumes to the new container when it is recreated
+	UpdateParallelism *int           `yaml:"update_parallelism,omitempty"` // recreate at most N containers extending the same parent at a time
+	Healthcheck       *Healthcheck   `yaml:"healthcheck,omitempty"`        // docker HEALTHCHECK, start and wait_for block until healthy
+	Ready             *ReadyCheck    `yaml:"ready,omitempty"`              // probes run by rocker-compose, start and wait_for block until they pass
+	Networks          Networks       `yaml:"networks,omitempty"`           // user-defined networks to connect to, either list or map of names
 
 	// Aliases, for compatibility with docker-compose and `docker run`
 
@@ -178,6 +208,81 @@ type Cmd []string
 // See yaml.go for more info.
 type Strings []string
 
+// Duration implements yaml [un]serializable time.Duration,
+// which is given as a string, e.g. "5s" or "1m30s"
+type Duration time.Duration
+
+// Healthcheck represents "healthcheck" property of the container spec,
+// which maps to HEALTHCHECK of docker. Test is either a list, e.g. [CMD, curl, -f, localhost],
+// or a string which runs with the default shell of the container; [NONE] disables
+// the healthcheck inherited from the image.
+type Healthcheck struct {
+	Test        HealthcheckTest `yaml:"test,omitempty"`
+	Interval    *Duration       `yaml:"interval,omitempty"`
+	Timeout     *Duration       `yaml:"timeout,omitempty"`
+	Retries     *int            `yaml:"retries,omitempty"`
+	StartPeriod *Duration       `yaml:"start_period,omitempty"`
+}
+
+// HealthcheckTest implements yaml [un]serializable "test" property of the healthcheck.
+// See yaml.go for more info.
+type HealthcheckTest []string
+
+// Networks implements yaml [un]serializable map of user-defined networks the container
+// is connected to, keyed by network name. See yaml.go for more info.
+type Networks map[string]*NetworkEndpoint
+
+// NetworkEndpoint represents the connection of a container to a network
+type NetworkEndpoint struct {
+	Aliases     Strings `yaml:"aliases,omitempty"`
+	IPv4Address string  `yaml:"ipv4_address,omitempty"`
+	IPv6Address string  `yaml:"ipv6_address,omitempty"`
+}
+
+// ReadyCheck represents "ready" property of the container spec. Unlike Healthcheck,
+// the probes are run by rocker-compose itself: TCP and HTTP ones connect to the container
+// from outside, Exec runs the command inside the container. If several probes are given,
+// all of them should pass. A probe is retried until it passes or Retries are exhausted.
+type ReadyCheck struct {
+	TCP      *int       `yaml:"tcp,omitempty"`
+	HTTP     *ReadyHTTP `yaml:"http,omitempty"`
+	Exec     Cmd        `yaml:"exec,omitempty"`
+	Timeout  *Duration  `yaml:"timeout,omitempty"`
+	Interval *Duration  `yaml:"interval,omitempty"`
+	Retries  *int       `yaml:"retries,omitempty"`
+}
+
+// ReadyHTTP is the HTTP GET probe of the ReadyCheck; any 2xx status
+// is expected unless Status is given
+type ReadyHTTP struct {
+	Port   int    `yaml:"port"`
+	Path   string `yaml:"path,omitempty"`
+	Status int    `yaml:"status,omitempty"`
+}
+
+// Extends represents "extends" property of the container spec: either a name of the container
+// of the same manifest or a container of another manifest, e.g. {file: base.yml, container: app}.
+// The file is relative to the manifest and is rendered with the same template variables.
+type Extends struct {
+	File      string `yaml:"file,omitempty"`
+	Container string `yaml:"container"`
+}
+
+// Secrets is a collection of secrets of the container, keyed by secret name
+type Secrets map[string]*Secret
+
+// Secret is a file put into the container before it starts. The content is either
+// read from the local File, relative to the manifest, or given as Value, which is
+// usually a template variable. Target is the path in the container, /run/secrets/<name>
+// by default. The container label keeps only a salted Hash of the content.
+type Secret struct {
+	File   string `yaml:"file,omitempty"`
+	Value  string `yaml:"value,omitempty"`
+	Target string `yaml:"target,omitempty"`
+	Mode   *int64 `yaml:"mode,omitempty"`
+	Hash   string `yaml:"hash,omitempty"`
+}
+
 // NewFromFile reads and parses config from a file.
 // If given filename is not absolute path, it resolves absolute name from the current
 // working directory. See ReadConfig/4 for reading and parsing details.
@@ -208,9 +313,166 @@ func NewFromFile(filename string, vars template.Vars, funcs map[string]interface
 	return config, nil
 }
 
+// NewFromFiles reads and parses config from several files, which are merged in the given
+// order: later files override containers of previous ones, see Config.Merge for details.
+// The namespace defaults to the name of the directory of the first file.
+func NewFromFiles(filenames []string, vars template.Vars, funcs map[string]interface{}, print bool) (*Config, error) {
+	if len(filenames) == 1 {
+		return NewFromFile(filenames[0], vars, funcs, print)
+	}
+
+	var (
+		config = &Config{}
+		files  = []string{}
+	)
+
+	for _, filename := range filenames {
+		filename, err := filepath.Abs(filename)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot get absolute path to %s due to error %s", filename, err)
+		}
+
+		fd, err := os.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open config file %s, error: %s", filename, err)
+		}
+
+		manifest, err := parseManifest(filename, fd, vars, funcs, print, nil, config.Volumes)
+		fd.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		config.Merge(manifest)
+		files = append(files, filename)
+	}
+
+	if print {
+		os.Exit(0)
+	}
+
+	if err := config.complete(files[0], vars, funcs, files[1:]); err != nil {
+		return nil, err
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
 // ReadConfig reads and parses the config from io.Reader stream.
 // Before parsing it processes config through a template engine implemented in template.go.
 func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs map[string]interface{}, print bool) (*Config, error) {
+	config, err := readManifest(configName, reader, vars, funcs, print, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// validate checks container specs of the manifest and sets the namespace
+// of the manifest for references to other containers and networks
+func (config *Config) validate() error {
+	for name, container := range config.Containers {
+		// Validate image
+		if container.Image == nil {
+			return fmt.Errorf("Image should be specified for container: %s", name)
+		}
+
+		img := imagename.NewFromString(*container.Image)
+
+		if !img.IsStrict() && !img.HasVersionRange() && !img.All() {
+			return fmt.Errorf("Image `%s` for container `%s`: image without tag is not allowed",
+				*container.Image, name)
+		}
+
+		// Validate ready probes
+		if container.Ready != nil && container.Ready.TCP == nil && container.Ready.HTTP == nil && len(container.Ready.Exec) == 0 {
+			return fmt.Errorf("Container %s: ready should have at least one of tcp, http or exec probes", name)
+		}
+
+		// Volumes are migrated only between containers that never run
+		if container.MigrateVolumes != nil && *container.MigrateVolumes && (container.State == nil || *container.State != "created") {
+			return fmt.Errorf("Container %s: migrate_volumes is only supported for containers with state: created", name)
+		}
+
+		// Set namespace for all containers inside
+		for k := range container.VolumesFrom {
+			container.VolumesFrom[k].DefaultNamespace(config.Namespace)
+		}
+		for k := range container.Links {
+			container.Links[k].DefaultNamespace(config.Namespace)
+		}
+		for k := range container.WaitFor {
+			container.WaitFor[k].DefaultNamespace(config.Namespace)
+		}
+		if container.Net != nil && container.Net.Type == "container" {
+			container.Net.Container.DefaultNamespace(config.Namespace)
+		}
+
+		// Validate networks and set namespace for them
+		if len(container.Networks) > 0 {
+			if container.Net != nil {
+				return fmt.Errorf("Container %s: net and networks cannot be specified together", name)
+			}
+			networks := Networks{}
+			for networkName, endpoint := range container.Networks {
+				n := NewContainerNameFromString(networkName)
+				n.DefaultNamespace(config.Namespace)
+				if _, ok := config.Networks[n.Name]; n.Namespace == config.Namespace && !ok {
+					return fmt.Errorf("Container %s: network %s is not defined in the manifest", name, networkName)
+				}
+				networks[n.String()] = endpoint
+			}
+			container.Networks = networks
+		}
+
+		// Fix exposed ports
+		for k, port := range container.Expose {
+			if !strings.Contains(port, "/") {
+				container.Expose[k] = port + "/tcp"
+			}
+		}
+	}
+
+	return nil
+}
+
+// readManifest parses the manifest and resolves extends, see parseManifest and complete.
+// Containers are not validated yet, since manifests read to extend from may have incomplete
+// specs. The files argument is the chain of manifests that extend from this one, which is
+// used to detect cycles.
+func readManifest(configName string, reader io.Reader, vars template.Vars, funcs map[string]interface{}, print bool, files []string) (*Config, error) {
+	config, err := parseManifest(configName, reader, vars, funcs, print, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if print {
+		os.Exit(0)
+	}
+
+	if err := config.complete(configName, vars, funcs, files); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// parseManifest renders and parses the manifest, merges it over the manifests listed
+// in its "include" property and processes aliases and local files referred by containers.
+// If print is true, the rendered manifest is printed and an empty config is returned.
+// The includes argument is the chain of manifests that include this one, which is used
+// to detect cycles. Volumes are named volumes of manifests this one is merged over,
+// so that references to them are not taken for local paths.
+func parseManifest(configName string, reader io.Reader, vars template.Vars, funcs map[string]interface{}, print bool, includes []string, volumes map[string]*Volume) (*Config, error) {
 	config := &Config{}
 
 	basedir, err := os.Getwd()
@@ -232,25 +494,16 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 
 	if print {
 		fmt.Print(data.String())
-		os.Exit(0)
+		return config, nil
 	}
 
 	if err := yaml.Unmarshal(data.Bytes(), config); err != nil {
 		return nil, fmt.Errorf("Failed to parse YAML config, error: %s", err)
 	}
 
-	// empty namespace is a backward compatible docker-compose format
-	// we will try to guess the namespace my parent directory name
-	if config.Namespace == "" {
-		parentDir := filepath.Base(basedir)
-		config.Namespace = regexp.MustCompile("[^a-z0-9\\-\\_]").ReplaceAllString(parentDir, "")
-	}
-
-	// Save vars to config
-	config.Vars = vars
-
 	// Read extra data
 	type ConfigExtra struct {
+		Include    Strings
 		Containers map[string]map[string]interface{}
 	}
 	extra := &ConfigExtra{}
@@ -276,6 +529,59 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 		return homeMemo, nil
 	}
 
+	// Function that resolves local paths given in the manifest, e.g. of volumes,
+	// relative to the manifest file; "~" is expanded to HOME
+	localPath := func(p string) (string, error) {
+		if strings.HasPrefix(p, "~") {
+			home, err := getHome()
+			if err != nil {
+				return "", fmt.Errorf("Failed to get HOME path, error: %s", err)
+			}
+			p = strings.Replace(p, "~", home, 1)
+		}
+		if !path.IsAbs(p) {
+			p = path.Join(basedir, p)
+		}
+		return p, nil
+	}
+
+	// Read included manifests in order, each next one is merged over previous ones
+	base := &Config{}
+
+	if abs, err := filepath.Abs(configName); err == nil {
+		includes = append(includes, abs)
+	}
+
+	for _, file := range extra.Include {
+		if file, err = localPath(file); err != nil {
+			return nil, err
+		}
+		if file, err = filepath.Abs(file); err != nil {
+			return nil, err
+		}
+		for _, f := range includes {
+			if f == file {
+				return nil, fmt.Errorf("Cyclic include of manifests: %s", strings.Join(append(includes, file), " -> "))
+			}
+		}
+
+		fd, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open included config file %s, error: %s", file, err)
+		}
+
+		included, err := parseManifest(file, fd, vars, funcs, false, includes, mergeVolumes(volumes, base.Volumes))
+		fd.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to include %s, error: %s", file, err)
+		}
+
+		base.Merge(included)
+	}
+
+	// Named volumes known so far, they are prefixed with the namespace in complete
+	volumes = mergeVolumes(volumes, base.Volumes, config.Volumes)
+
 	// Process aliases on the first run, have to do it before extends
 	// because Golang randomizes maps, sometimes inherited containers
 	// process earlier then dependencies; also do initial validation
@@ -327,6 +633,73 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 			container.Environment = nil
 		}
 
+		// Manifests to extend from are relative to this one
+		if container.Extends != nil && container.Extends.File != "" {
+			if container.Extends.File, err = localPath(container.Extends.File); err != nil {
+				return nil, err
+			}
+		}
+
+		// Read env files, explicit env takes precedence
+		if len(container.EnvFile) > 0 {
+			env := StringMap{}
+			for i, file := range container.EnvFile {
+				if container.EnvFile[i], err = localPath(file); err != nil {
+					return nil, err
+				}
+				fileEnv, err := ReadEnvFile(container.EnvFile[i])
+				if err != nil {
+					return nil, fmt.Errorf("Container %s: %s", name, err)
+				}
+				for k, v := range fileEnv {
+					env[k] = v
+				}
+			}
+			for k, v := range container.Env {
+				env[k] = v
+			}
+			container.Env = env
+		}
+
+		// Read secrets
+		for secretName, secret := range container.Secrets {
+			if secret == nil || (secret.File == "") == (secret.Value == "") {
+				return nil, fmt.Errorf("Container %s: secret %s should have either file or value", name, secretName)
+			}
+			if secret.File != "" {
+				if secret.File, err = localPath(secret.File); err != nil {
+					return nil, err
+				}
+				content, err := ioutil.ReadFile(secret.File)
+				if err != nil {
+					return nil, fmt.Errorf("Container %s: failed to read secret %s, error: %s", name, secretName, err)
+				}
+				secret.Value = string(content)
+			}
+			if secret.Target == "" {
+				secret.Target = secretName
+			}
+			if !path.IsAbs(secret.Target) {
+				secret.Target = path.Join("/run/secrets", secret.Target)
+			}
+		}
+
+		// Process relative paths in volumes
+		for i, volume := range container.Volumes {
+			split := strings.SplitN(volume, ":", 2)
+			if len(split) == 1 {
+				continue
+			}
+			// named volume of the manifest
+			if _, ok := volumes[split[0]]; ok {
+				continue
+			}
+			if split[0], err = localPath(split[0]); err != nil {
+				return nil, err
+			}
+			container.Volumes[i] = strings.Join(split, ":")
+		}
+
 		// Process extra data
 		extraFields := map[string]interface{}{}
 		for key, val := range extra.Containers[name] {
@@ -341,77 +714,148 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 		// pretty.Println(name, container.Extra)
 	}
 
-	// Process extending containers configuration
-	for name, container := range config.Containers {
-		if container.Extends != "" {
-			if container.Extends == name {
-				return nil, fmt.Errorf("Container %s: cannot extend from itself", name)
-			}
-			if _, ok := config.Containers[container.Extends]; !ok {
-				return nil, fmt.Errorf("Container %s: cannot find container %s to extend from", name, container.Extends)
-			}
-			// TODO: build dependency graph by extends hierarchy to allow multiple inheritance
-			if config.Containers[container.Extends].Extends != "" {
-				return nil, fmt.Errorf("Container %s: cannot extend from %s: multiple inheritance is not allowed yet",
-					name, container.Extends)
-			}
-			container.ExtendFrom(config.Containers[container.Extends])
-		}
+	if len(extra.Include) == 0 {
+		return config, nil
+	}
 
-		// Validate image
-		if container.Image == nil {
-			return nil, fmt.Errorf("Image should be specified for container: %s", name)
+	base.Merge(config)
+
+	return base, nil
+}
+
+// complete finishes reading of the manifest: sets the namespace by the directory of the manifest
+// if it is not given, resolves extending containers and prefixes named volumes of the manifest
+// with the namespace. The files argument is the chain of manifests that extend from this one.
+func (config *Config) complete(configName string, vars template.Vars, funcs map[string]interface{}, files []string) error {
+	// empty namespace is a backward compatible docker-compose format
+	// we will try to guess the namespace my parent directory name
+	if config.Namespace == "" {
+		basedir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("Failed to get working dir, error: %s", err)
+		}
+		if configName != "-" {
+			basedir = filepath.Dir(configName)
 		}
+		parentDir := filepath.Base(basedir)
+		config.Namespace = regexp.MustCompile("[^a-z0-9\\-\\_]").ReplaceAllString(parentDir, "")
+	}
 
-		img := imagename.NewFromString(*container.Image)
+	// Save vars to config
+	config.Vars = vars
 
-		if !img.IsStrict() && !img.HasVersionRange() && !img.All() {
-			return nil, fmt.Errorf("Image `%s` for container `%s`: image without tag is not allowed",
-				*container.Image, name)
+	// Process extending containers configuration, parents are resolved before
+	// their children, so containers can extend from ones that extend others
+	var (
+		resolved  = map[string]bool{}
+		manifests = map[string]*Config{}
+		resolve   func(name string, chain []string) error
+	)
+
+	if abs, err := filepath.Abs(configName); err == nil {
+		files = append(files, abs)
+	}
+
+	readParentManifest := func(file string) (*Config, error) {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		if manifest, ok := manifests[file]; ok {
+			return manifest, nil
+		}
+		for _, f := range files {
+			if f == file {
+				return nil, fmt.Errorf("cyclic extends between manifests: %s", strings.Join(append(files, file), " -> "))
+			}
 		}
 
-		// Set namespace for all containers inside
-		for k := range container.VolumesFrom {
-			container.VolumesFrom[k].DefaultNamespace(config.Namespace)
+		fd, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open config file %s, error: %s", file, err)
 		}
-		for k := range container.Links {
-			container.Links[k].DefaultNamespace(config.Namespace)
+		defer fd.Close()
+
+		manifest, err := readManifest(file, fd, vars, funcs, false, files)
+		if err != nil {
+			return nil, err
 		}
-		for k := range container.WaitFor {
-			container.WaitFor[k].DefaultNamespace(config.Namespace)
+		manifests[file] = manifest
+
+		return manifest, nil
+	}
+
+	resolve = func(name string, chain []string) error {
+		container := config.Containers[name]
+		if resolved[name] || container.Extends == nil {
+			return nil
 		}
-		if container.Net != nil && container.Net.Type == "container" {
-			container.Net.Container.DefaultNamespace(config.Namespace)
+
+		for _, n := range chain {
+			if n == name {
+				return fmt.Errorf("Container %s: cyclic extends: %s", name, strings.Join(append(chain, name), " -> "))
+			}
 		}
+		chain = append(chain, name)
 
-		// Fix exposed ports
-		for k, port := range container.Expose {
-			if !strings.Contains(port, "/") {
-				container.Expose[k] = port + "/tcp"
+		var (
+			parentName = container.Extends.Container
+			parent     *Container
+		)
+
+		if container.Extends.File == "" {
+			if _, ok := config.Containers[parentName]; !ok {
+				return fmt.Errorf("Container %s: cannot find container %s to extend from", name, parentName)
+			}
+			if err := resolve(parentName, chain); err != nil {
+				return err
+			}
+			parent = config.Containers[parentName]
+		} else {
+			manifest, err := readParentManifest(container.Extends.File)
+			if err != nil {
+				return fmt.Errorf("Container %s: failed to read %s to extend from, error: %s", name, container.Extends.File, err)
+			}
+			if parent = manifest.Containers[parentName]; parent == nil {
+				return fmt.Errorf("Container %s: cannot find container %s in %s to extend from", name, parentName, container.Extends.File)
 			}
 		}
 
-		// Process relative paths in volumes
+		container.ExtendFrom(parent)
+		resolved[name] = true
+
+		return nil
+	}
+
+	for name := range config.Containers {
+		if err := resolve(name, nil); err != nil {
+			return err
+		}
+	}
+
+	// Named volumes are prefixed with the namespace once containers got them from parents
+	for _, container := range config.Containers {
 		for i, volume := range container.Volumes {
 			split := strings.SplitN(volume, ":", 2)
-			if len(split) == 1 {
-				continue
+			if _, ok := config.Volumes[split[0]]; ok && len(split) == 2 {
+				split[0] = NewContainerName(config.Namespace, split[0]).String()
+				container.Volumes[i] = strings.Join(split, ":")
 			}
-			if strings.HasPrefix(split[0], "~") {
-				home, err := getHome()
-				if err != nil {
-					return nil, fmt.Errorf("Failed to get HOME path, error: %s", err)
-				}
-				split[0] = strings.Replace(split[0], "~", home, 1)
-			}
-			if !path.IsAbs(split[0]) {
-				split[0] = path.Join(basedir, split[0])
-			}
-			container.Volumes[i] = strings.Join(split, ":")
 		}
 	}
 
-	return config, nil
+	return nil
+}
+
+// mergeVolumes returns a union of the given maps of named volumes
+func mergeVolumes(maps ...map[string]*Volume) map[string]*Volume {
+	volumes := map[string]*Volume{}
+	for _, m := range maps {
+		for name, volume := range m {
+			volumes[name] = volume
+		}
+	}
+	return volumes
 }
 
 // HasExternalRefs returns true if there is at least one reference to the external namespace
@@ -559,6 +1003,15 @@ func (n ContainerName) String() string {
 	return name
 }
 
+// String gives a string representation of the container to extend from,
+// which is prefixed with the file if it is in another manifest, e.g. base.yml:app
+func (e Extends) String() string {
+	if e.File == "" {
+		return e.Container
+	}
+	return e.File + ":" + e.Container
+}
+
 // String is same as ContainerName.String() but adds alias
 func (link Link) String() string {
 	name := link.ContainerName.String()
@@ -637,6 +1090,59 @@ func (state *State) Bool() bool {
 	return true // "running" or anything else
 }
 
+// Names returns the sorted list of network names
+func (v Networks) Names() []string {
+	names := []string{}
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ToDockerAPI converts NetworkEndpoint to a docker.EndpointConfig object
+// which is eatable by go-dockerclient.
+func (e *NetworkEndpoint) ToDockerAPI() *docker.EndpointConfig {
+	endpoint := &docker.EndpointConfig{}
+	if e == nil {
+		return endpoint
+	}
+	endpoint.Aliases = e.Aliases
+	if e.IPv4Address != "" || e.IPv6Address != "" {
+		endpoint.IPAMConfig = &docker.EndpointIPAMConfig{
+			IPv4Address: e.IPv4Address,
+			IPv6Address: e.IPv6Address,
+		}
+	}
+	return endpoint
+}
+
+// Duration returns time.Duration value of the Duration object
+func (d *Duration) Duration() time.Duration {
+	if d == nil {
+		return 0
+	}
+	return (time.Duration)(*d)
+}
+
+// ToDockerAPI converts Healthcheck to a docker.HealthConfig object
+// which is eatable by go-dockerclient.
+func (h *Healthcheck) ToDockerAPI() *docker.HealthConfig {
+	if h == nil {
+		return nil
+	}
+	healthConfig := &docker.HealthConfig{
+		Test:        h.Test,
+		Interval:    h.Interval.Duration(),
+		Timeout:     h.Timeout.Duration(),
+		StartPeriod: h.StartPeriod.Duration(),
+	}
+	if h.Retries != nil {
+		healthConfig.Retries = *h.Retries
+	}
+	return healthConfig
+}
+
 // IsRan returns true if state is "ran"
 func (state *State) IsRan() bool {
 	return state != nil && *state == "ran"
@@ -17,6 +17,9 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path"
 	"strings"
 	"testing"
 
@@ -91,6 +94,194 @@ containers:
 	assert.Equal(t, "Image should be specified for container: test", err.Error())
 }
 
+func TestConfigNetworks(t *testing.T) {
+	configStr := `namespace: test
+networks:
+  backend:
+    subnet: 172.28.0.0/16
+  frontend:
+containers:
+  db:
+    image: mysql:5.6
+    networks: backend
+  app:
+    image: quay.io/myapp:1.0
+    networks:
+      frontend:
+      backend:
+        aliases: [api]
+        ipv4_address: 172.28.0.10
+      monitoring.metrics:`
+
+	config, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "172.28.0.0/16", *config.Networks["backend"].Subnet)
+	assert.Equal(t, []string{"test.backend"}, config.Containers["db"].Networks.Names())
+	assert.Equal(t, []string{"monitoring.metrics", "test.backend", "test.frontend"}, config.Containers["app"].Networks.Names())
+
+	// created in the first network, connected to the rest later
+	hostConfig := config.Containers["app"].GetAPIHostConfig()
+	assert.Equal(t, "monitoring.metrics", hostConfig.NetworkMode)
+	networkingConfig := config.Containers["app"].GetAPINetworkingConfig()
+	assert.Len(t, networkingConfig.EndpointsConfig, 1)
+
+	endpoint := config.Containers["app"].Networks["test.backend"].ToDockerAPI()
+	assert.Equal(t, []string{"api"}, endpoint.Aliases)
+	assert.Equal(t, "172.28.0.10", endpoint.IPAMConfig.IPv4Address)
+
+	configStr = `namespace: test
+containers:
+  db:
+    image: mysql:5.6
+    networks: backend`
+
+	_, err = ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, "Container db: network backend is not defined in the manifest")
+}
+
+func TestConfigNamedVolumes(t *testing.T) {
+	configStr := `namespace: test
+volumes:
+  data:
+    driver: local
+containers:
+  db:
+    image: mysql:5.6
+    volumes:
+      - data:/var/lib/mysql
+      - conf:/etc/mysql/conf.d`
+
+	config, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "local", *config.Volumes["data"].Driver)
+	assert.Equal(t, "test.data:/var/lib/mysql", config.Containers["db"].Volumes[0])
+	// undeclared names are still paths relative to the manifest
+	assert.Equal(t, "conf:/etc/mysql/conf.d", config.Containers["db"].Volumes[1])
+}
+
+func TestConfigMigrateVolumes(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  data:
+    image: busybox:latest
+    state: created
+    migrate_volumes: true
+  db:
+    image: mysql:5.6
+    migrate_volumes: true`
+
+	_, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, "Container db: migrate_volumes is only supported for containers with state: created")
+
+	config, err := ReadConfig("test", strings.NewReader(configStr[:strings.Index(configStr, "  db:")]), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, *config.Containers["data"].MigrateVolumes)
+}
+
+func TestConfigSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(path.Join(dir, "db_password"), []byte("qwerty"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	configStr := `namespace: test
+containers:
+  db:
+    image: mysql:5.6
+    secrets:
+      db_password:
+        file: db_password
+      api_token:
+        value: "{{ .token }}"
+        target: /etc/app/token
+        mode: 0400`
+
+	vars := template.Vars{"token": "abc"}
+
+	config, err := ReadConfig(path.Join(dir, "compose.yml"), strings.NewReader(configStr), vars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := config.Containers["db"].Secrets
+	assert.Equal(t, path.Join(dir, "db_password"), secrets["db_password"].File)
+	assert.Equal(t, "qwerty", secrets["db_password"].Value)
+	assert.Equal(t, "/run/secrets/db_password", secrets["db_password"].Target)
+	assert.Equal(t, "abc", secrets["api_token"].Value)
+	assert.Equal(t, "/etc/app/token", secrets["api_token"].Target)
+	assert.EqualValues(t, 0400, *secrets["api_token"].Mode)
+
+	_, err = ReadConfig("test", strings.NewReader("namespace: test\ncontainers:\n  db:\n    image: mysql:5.6\n    secrets:\n      x: {}"),
+		configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, "Container db: secret x should have either file or value")
+}
+
+func TestConfigEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-env-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeEnv := func(name, content string) {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeEnv("common.env", "LOG_LEVEL=info\nDB_HOST=db\n")
+	writeEnv("app.env", "DB_HOST=db.local\nWORKERS=4\n")
+
+	configStr := `namespace: test
+containers:
+  _base:
+    image: quay.io/myapp:1.0
+    env_file: common.env
+  app:
+    extends: _base
+    env_file:
+      - common.env
+      - app.env
+    env:
+      WORKERS: 8
+  worker:
+    extends: _base`
+
+	readConfig := func() *Config {
+		config, err := ReadConfig(path.Join(dir, "compose.yml"), strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config
+	}
+
+	config := readConfig()
+
+	// later files override earlier ones, explicit env overrides files
+	assert.Equal(t, StringMap{"LOG_LEVEL": "info", "DB_HOST": "db.local", "WORKERS": "8"}, config.Containers["app"].Env)
+	assert.Equal(t, Strings{path.Join(dir, "common.env"), path.Join(dir, "app.env")}, config.Containers["app"].EnvFile)
+
+	assert.Equal(t, StringMap{"LOG_LEVEL": "info", "DB_HOST": "db"}, config.Containers["worker"].Env)
+	assert.Equal(t, Strings{path.Join(dir, "common.env")}, config.Containers["worker"].EnvFile)
+
+	// editing the file changes the spec
+	writeEnv("common.env", "LOG_LEVEL=debug\nDB_HOST=db\n")
+	assert.False(t, readConfig().Containers["worker"].IsEqualTo(config.Containers["worker"]))
+	assert.False(t, readConfig().Containers["app"].IsEqualTo(config.Containers["app"]))
+}
+
 func TestNewContainerNameFromString(t *testing.T) {
 	type assertion struct {
 		namespace string
@@ -56,9 +56,59 @@ func NewFromDocker(apiContainer *docker.Container) (*Container, error) {
 		}
 	}
 
+	if apiContainer.HostConfig != nil {
+		container.applyLiveHostConfig(apiContainer.HostConfig)
+	}
+
 	return container, nil
 }
 
+// applyLiveHostConfig takes the properties that can be changed on the existing
+// container with 'docker update' from the actual host config. Docker cannot change
+// labels, so the spec stored in the label does not reflect such updates.
+// Zero values are ignored, since docker reports them for unset properties
+// and fills memory_swap with twice the memory limit by default.
+func (config *Container) applyLiveHostConfig(hostConfig *docker.HostConfig) {
+	var (
+		labelConfig     = config.GetAPIConfig()
+		labelHostConfig = config.GetAPIHostConfig()
+	)
+
+	if hostConfig.Memory > 0 && hostConfig.Memory != labelHostConfig.Memory {
+		config.Memory = NewConfigMemoryFromInt64(hostConfig.Memory)
+	}
+
+	if swap := hostConfig.MemorySwap; swap != labelHostConfig.MemorySwap {
+		if config.MemorySwap != nil || (swap > 0 && swap != hostConfig.Memory*2) {
+			config.MemorySwap = NewConfigMemoryFromInt64(swap)
+		}
+	}
+
+	if hostConfig.CPUShares > 0 && hostConfig.CPUShares != labelConfig.CPUShares {
+		cpuShares := hostConfig.CPUShares
+		config.CPUShares = &cpuShares
+	}
+
+	cpusetCpus := hostConfig.CPUSetCPUs
+	if cpusetCpus == "" {
+		cpusetCpus = hostConfig.CPUSet
+	}
+	if cpusetCpus != "" && cpusetCpus != labelHostConfig.CPUSet {
+		config.CpusetCpus = &cpusetCpus
+	}
+
+	restart, labelRestart := hostConfig.RestartPolicy, labelHostConfig.RestartPolicy
+	if restart.Name == "" {
+		restart.Name = "no"
+	}
+	if labelRestart.Name == "" {
+		labelRestart.Name = "no"
+	}
+	if restart != labelRestart {
+		config.Restart = &RestartPolicy{restart.Name, restart.MaximumRetryCount}
+	}
+}
+
 // GetAPIConfig as an opposite from NewFromDocker - it returns docker.Config that can be used
 // to run containers through the docker api.
 func (config *Container) GetAPIConfig() *docker.Config {
@@ -100,6 +150,9 @@ func (config *Container) GetAPIConfig() *docker.Config {
 	if config.NetworkDisabled != nil {
 		apiConfig.NetworkDisabled = *config.NetworkDisabled
 	}
+	if config.Healthcheck != nil {
+		apiConfig.Healthcheck = config.Healthcheck.ToDockerAPI()
+	}
 
 	// expose
 	if len(config.Expose) > 0 || len(config.Ports) > 0 {
@@ -156,6 +209,11 @@ func (config *Container) GetAPIHostConfig() *docker.HostConfig {
 		NetworkMode:   config.Net.String(),
 	}
 
+	// the container is created in the first of its networks, see GetAPINetworkingConfig
+	if networks := config.Networks.Names(); len(networks) > 0 {
+		hostConfig.NetworkMode = networks[0]
+	}
+
 	// if state is "running", then restart policy sould be "always" by default
 	if config.State.Bool() && config.Restart == nil {
 		hostConfig.RestartPolicy = (&RestartPolicy{"always", 0}).ToDockerAPI()
@@ -258,3 +316,19 @@ func (config *Container) GetAPIHostConfig() *docker.HostConfig {
 
 	return hostConfig
 }
+
+// GetAPINetworkingConfig returns docker.NetworkingConfig for creating the container
+// through the docker api. Docker connects a new container to a single network only,
+// so it has the endpoint of the first network; the container should be connected
+// to the rest of them before starting.
+func (config *Container) GetAPINetworkingConfig() *docker.NetworkingConfig {
+	networks := config.Networks.Names()
+	if len(networks) == 0 {
+		return nil
+	}
+	return &docker.NetworkingConfig{
+		EndpointsConfig: map[string]*docker.EndpointConfig{
+			networks[0]: config.Networks[networks[0]].ToDockerAPI(),
+		},
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/fsouza/go-dockerclient"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -70,3 +71,48 @@ func TestConfigGetApiHostConfig(t *testing.T) {
 
 	assert.Equal(t, strings.TrimSpace(string(expected)), string(actual))
 }
+
+func TestConfigNewFromDockerLiveUpdate(t *testing.T) {
+	apiContainer := &docker.Container{
+		Config: &docker.Config{
+			Labels: map[string]string{
+				"rocker-compose-config": "memory: 300M\ncpu_shares: 512\n",
+			},
+		},
+		HostConfig: &docker.HostConfig{
+			Memory:        512 * 1024 * 1024,
+			MemorySwap:    1024 * 1024 * 1024,
+			CPUShares:     512,
+			CPUSetCPUs:    "0-2",
+			RestartPolicy: docker.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+		},
+	}
+
+	config, err := NewFromDocker(apiContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.EqualValues(t, 512*1024*1024, config.Memory.Int64())
+	// twice the memory is the docker default, should not appear in the spec
+	assert.Nil(t, config.MemorySwap)
+	assert.EqualValues(t, 512, *config.CPUShares)
+	assert.Equal(t, "0-2", *config.CpusetCpus)
+	assert.Equal(t, &RestartPolicy{"on-failure", 3}, config.Restart)
+
+	// running container without explicit restart policy gets "always"
+	apiContainer.HostConfig = &docker.HostConfig{
+		Memory:        300 * 1024 * 1024,
+		CPUShares:     512,
+		RestartPolicy: docker.RestartPolicy{Name: "always"},
+	}
+
+	config, err = NewFromDocker(apiContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.EqualValues(t, 300*1024*1024, config.Memory.Int64())
+	assert.Nil(t, config.CpusetCpus)
+	assert.Nil(t, config.Restart)
+}
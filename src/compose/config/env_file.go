@@ -0,0 +1,67 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadEnvFile parses the file of env vars in the format of 'docker run --env-file':
+// KEY=VALUE lines, where empty lines and lines starting with # are ignored.
+// Values are taken as is, without unquoting. A line with KEY only takes the
+// value of the var from the environment of rocker-compose, if it is set.
+func ReadEnvFile(file string) (StringMap, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read env file %s, error: %s", file, err)
+	}
+	defer fd.Close()
+
+	env := StringMap{}
+	scanner := bufio.NewScanner(fd)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		split := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(split[0])
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("Invalid variable name %q in env file %s, line %d", split[0], file, n)
+		}
+
+		if len(split) == 1 {
+			if value, ok := os.LookupEnv(key); ok {
+				env[key] = value
+			}
+			continue
+		}
+
+		env[key] = split[1]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read env file %s, error: %s", file, err)
+	}
+
+	return env, nil
+}
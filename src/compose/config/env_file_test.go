@@ -0,0 +1,61 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	fd, err := ioutil.TempFile("", "rocker-compose-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fd.Name())
+
+	os.Setenv("ROCKER_COMPOSE_TEST_HOST_VAR", "from host")
+	defer os.Unsetenv("ROCKER_COMPOSE_TEST_HOST_VAR")
+
+	fd.WriteString(`# database
+DB_HOST=db
+DB_PASSWORD="qwe=rty"
+
+  EMPTY=
+ROCKER_COMPOSE_TEST_HOST_VAR
+ROCKER_COMPOSE_TEST_UNSET_VAR
+`)
+	fd.Close()
+
+	env, err := ReadEnvFile(fd.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, StringMap{
+		"DB_HOST":                      "db",
+		"DB_PASSWORD":                  `"qwe=rty"`,
+		"EMPTY":                        "",
+		"ROCKER_COMPOSE_TEST_HOST_VAR": "from host",
+	}, env)
+
+	_, err = ReadEnvFile(fd.Name() + ".missing")
+	assert.Error(t, err)
+}
@@ -90,6 +90,21 @@ func (container *Container) ExtendFrom(parent *Container) {
 	if container.KeepVolumes == nil {
 		container.KeepVolumes = parent.KeepVolumes
 	}
+	if container.MigrateVolumes == nil {
+		container.MigrateVolumes = parent.MigrateVolumes
+	}
+	if container.UpdateParallelism == nil {
+		container.UpdateParallelism = parent.UpdateParallelism
+	}
+	if container.Healthcheck == nil {
+		container.Healthcheck = parent.Healthcheck
+	}
+	if container.Ready == nil {
+		container.Ready = parent.Ready
+	}
+	if container.Networks == nil {
+		container.Networks = parent.Networks
+	}
 	// Extend labels
 	newLabels := make(map[string]string)
 	for k, v := range parent.Labels {
@@ -110,6 +125,36 @@ func (container *Container) ExtendFrom(parent *Container) {
 	}
 	container.Env = newEnv
 
+	if container.EnvFile == nil {
+		container.EnvFile = parent.EnvFile
+	}
+
+	// Extend secrets
+	if container.Secrets != nil || parent.Secrets != nil {
+		newSecrets := Secrets{}
+		for k, v := range parent.Secrets {
+			newSecrets[k] = v
+		}
+		for k, v := range container.Secrets {
+			newSecrets[k] = v
+		}
+		container.Secrets = newSecrets
+	}
+
+	// Extend secret env, a child cannot reveal secrets of the parent
+	newSecretEnv := Strings{}
+	for _, pattern := range parent.SecretEnv {
+		newSecretEnv = append(newSecretEnv, pattern)
+	}
+	for _, pattern := range container.SecretEnv {
+		if !parent.IsSecretEnv(pattern) {
+			newSecretEnv = append(newSecretEnv, pattern)
+		}
+	}
+	if len(newSecretEnv) > 0 {
+		container.SecretEnv = newSecretEnv
+	}
+
 	if container.Links == nil {
 		container.Links = parent.Links
 	}
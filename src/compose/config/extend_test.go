@@ -17,6 +17,11 @@
 package config
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -54,3 +59,110 @@ func TestConfigExtend(t *testing.T) {
 	// should be overriden
 	assert.EqualValues(t, 200, *config.Containers["main2"].KillTimeout)
 }
+
+func TestConfigExtendMultiLevel(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  _base:
+    image: quay.io/myapp:1.0
+    dns: 8.8.8.8
+    env:
+      LOG_LEVEL: info
+  _web:
+    extends: _base
+    env:
+      PORT: 80
+  web:
+    extends: _web
+    env:
+      LOG_LEVEL: debug`
+
+	config, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := config.Containers["web"]
+	assert.Equal(t, "quay.io/myapp:1.0", *web.Image)
+	assert.Equal(t, Strings{"8.8.8.8"}, web.DNS)
+	assert.Equal(t, StringMap{"LOG_LEVEL": "debug", "PORT": "80"}, web.Env)
+	assert.Equal(t, StringMap{"LOG_LEVEL": "info", "PORT": "80"}, config.Containers["_web"].Env)
+}
+
+func TestConfigExtendCycle(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  a:
+    image: quay.io/myapp:1.0
+    extends: b
+  b:
+    extends: c
+  c:
+    extends: a`
+
+	_, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cyclic extends")
+
+	_, err = ReadConfig("test", strings.NewReader("containers:\n  a:\n    extends: a"), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, "Container a: cyclic extends: a -> a")
+
+	_, err = ReadConfig("test", strings.NewReader("containers:\n  a:\n    extends: b"), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, "Container a: cannot find container b to extend from")
+}
+
+func TestConfigExtendFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-extends")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) {
+		if err := os.MkdirAll(path.Dir(path.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// base manifests are templated with the same vars and their relative paths
+	// are resolved relative to them
+	writeFile("base/base.yml", `namespace: base
+containers:
+  _app:
+    image: quay.io/myapp:{{ .version.myapp }}
+    volumes: logs:/var/log/app
+  app:
+    extends: _app
+    links: db`)
+	writeFile("compose.yml", `namespace: test
+containers:
+  web:
+    extends:
+      file: base/base.yml
+      container: app
+    ports: "8080:80"`)
+
+	config, err := NewFromFile(path.Join(dir, "compose.yml"), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := config.Containers["web"]
+	assert.Equal(t, "quay.io/myapp:1.9.2", *web.Image)
+	assert.Equal(t, Strings{path.Join(dir, "base/logs") + ":/var/log/app"}, web.Volumes)
+	assert.Equal(t, "test.db", web.Links[0].ContainerName.String())
+	assert.Equal(t, &Extends{File: path.Join(dir, "base/base.yml"), Container: "app"}, web.Extends)
+	assert.Len(t, config.Containers, 1)
+
+	writeFile("compose.yml", "namespace: test\ncontainers:\n  web:\n    extends:\n      file: base/base.yml\n      container: worker")
+	_, err = NewFromFile(path.Join(dir, "compose.yml"), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, fmt.Sprintf("Container web: cannot find container worker in %s to extend from", path.Join(dir, "base/base.yml")))
+
+	writeFile("base/base.yml", "containers:\n  app:\n    extends:\n      file: ../compose.yml\n      container: web")
+	_, err = NewFromFile(path.Join(dir, "compose.yml"), configTestVars, map[string]interface{}{}, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cyclic extends between manifests")
+}
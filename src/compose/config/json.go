@@ -0,0 +1,66 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-yaml/yaml"
+)
+
+// MarshalJSON serializes the container spec to JSON. It goes through the YAML
+// representation, so JSON has the same properties and formats as compose.yml.
+func (config *Container) MarshalJSON() ([]byte, error) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonCompatible(value))
+}
+
+// UnmarshalJSON unserializes the container spec from JSON. Since JSON is
+// a subset of YAML, we simply parse it as YAML.
+func (config *Container) UnmarshalJSON(data []byte) error {
+	return yaml.Unmarshal(data, config)
+}
+
+// jsonCompatible converts maps given by the YAML parser, which have keys
+// of interface{} type, to maps with string keys, which json package can handle
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for key, val := range v {
+			result[fmt.Sprintf("%v", key)] = jsonCompatible(val)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, val := range v {
+			result[i] = jsonCompatible(val)
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,53 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONContainer(t *testing.T) {
+	config, err := NewFromFile("testdata/compose.yml", configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(config.Containers["main"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "quay.io/myapp:1.9.2", raw["image"])
+	assert.Equal(t, "always", raw["restart"])
+
+	container := &Container{}
+	if err := json.Unmarshal(data, container); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, config.Containers["main"].IsEqualTo(container),
+		"container spec converted from JSON should be equal to the original one, failed on field: %s", container.LastCompareField())
+	assert.Equal(t, *config.Containers["main"].Image, *container.Image)
+}
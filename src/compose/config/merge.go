@@ -0,0 +1,96 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import "reflect"
+
+// Merge merges the override manifest over this one, it is used for included manifests
+// and for multiple manifest files given. Containers specified in both manifests are merged
+// field by field, see Container.Merge; networks and volumes are replaced by name.
+func (config *Config) Merge(override *Config) {
+	if override.Namespace != "" {
+		config.Namespace = override.Namespace
+	}
+
+	for name, container := range override.Containers {
+		if config.Containers == nil {
+			config.Containers = map[string]*Container{}
+		}
+		if existing, ok := config.Containers[name]; ok && existing != nil && container != nil {
+			existing.Merge(container)
+			continue
+		}
+		config.Containers[name] = container
+	}
+
+	for name, network := range override.Networks {
+		if config.Networks == nil {
+			config.Networks = map[string]*Network{}
+		}
+		config.Networks[name] = network
+	}
+
+	for name, volume := range override.Volumes {
+		if config.Volumes == nil {
+			config.Volumes = map[string]*Volume{}
+		}
+		config.Volumes[name] = volume
+	}
+}
+
+// Merge sets the fields of the container that are specified in the override spec.
+// Maps, such as env or labels, are merged by key, the override values take precedence;
+// the rest of fields, including lists, are replaced entirely.
+func (container *Container) Merge(override *Container) {
+	var (
+		dst = reflect.ValueOf(container).Elem()
+		src = reflect.ValueOf(override).Elem()
+	)
+
+	for i := 0; i < dst.NumField(); i++ {
+		field, value := dst.Field(i), src.Field(i)
+
+		if !field.CanSet() || isEmptyField(value) {
+			continue
+		}
+
+		if value.Kind() == reflect.Map && !field.IsNil() {
+			merged := reflect.MakeMap(field.Type())
+			for _, key := range field.MapKeys() {
+				merged.SetMapIndex(key, field.MapIndex(key))
+			}
+			for _, key := range value.MapKeys() {
+				merged.SetMapIndex(key, value.MapIndex(key))
+			}
+			field.Set(merged)
+			continue
+		}
+
+		field.Set(value)
+	}
+}
+
+// isEmptyField returns true if the field of the container spec is not specified
+func isEmptyField(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return value.IsNil()
+	case reflect.String:
+		return value.String() == ""
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerMerge(t *testing.T) {
+	image := "quay.io/app:1.0"
+	image2 := "quay.io/app:2.0"
+
+	container := &Container{
+		Image:   &image,
+		Cmd:     Cmd{"run"},
+		Env:     StringMap{"FOO": "foo", "BAR": "bar"},
+		Labels:  StringMap{"role": "app"},
+		Volumes: Strings{"/data:/data", "/logs:/logs"},
+	}
+	env := container.Env
+
+	container.Merge(&Container{
+		Image:   &image2,
+		Env:     StringMap{"BAR": "baz", "QUX": "qux"},
+		Volumes: Strings{"/tmp:/tmp"},
+	})
+
+	assert.Equal(t, "quay.io/app:2.0", *container.Image)
+	assert.Equal(t, Cmd{"run"}, container.Cmd)
+	assert.Equal(t, StringMap{"FOO": "foo", "BAR": "baz", "QUX": "qux"}, container.Env)
+	assert.Equal(t, StringMap{"role": "app"}, container.Labels)
+	assert.Equal(t, Strings{"/tmp:/tmp"}, container.Volumes)
+
+	// the original map is not modified
+	assert.Equal(t, StringMap{"FOO": "foo", "BAR": "bar"}, env)
+}
+
+func TestConfigInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) {
+		if err := os.MkdirAll(path.Dir(path.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the base manifest has no namespace and its paths are relative to it
+	writeFile("base/base.yml", `volumes:
+  data: {}
+containers:
+  app:
+    image: quay.io/myapp:{{ .version.myapp }}
+    env:
+      FOO: foo
+      BAR: bar
+    volumes:
+      - data:/data
+      - logs:/var/log/app
+    links: db
+  db:
+    image: postgres:9.4`)
+	writeFile("prod.yml", `namespace: prod
+include:
+  - base/base.yml
+containers:
+  app:
+    env:
+      BAR: baz
+    ports: "8080:80"
+  worker:
+    extends: app
+    cmd: ["work"]`)
+
+	config, err := NewFromFile(path.Join(dir, "prod.yml"), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "prod", config.Namespace)
+	assert.Len(t, config.Containers, 3)
+	assert.NotNil(t, config.Volumes["data"])
+
+	app := config.Containers["app"]
+	assert.Equal(t, "quay.io/myapp:1.9.2", *app.Image)
+	assert.Equal(t, StringMap{"FOO": "foo", "BAR": "baz"}, app.Env)
+	assert.Equal(t, Strings{"prod.data:/data", path.Join(dir, "base/logs") + ":/var/log/app"}, app.Volumes)
+	assert.Equal(t, "8080", app.Ports[0].HostPort)
+	assert.Equal(t, "prod.db", app.Links[0].ContainerName.String())
+
+	worker := config.Containers["worker"]
+	assert.Equal(t, "quay.io/myapp:1.9.2", *worker.Image)
+	assert.Equal(t, Cmd{"work"}, worker.Cmd)
+
+	writeFile("base/base.yml", "include: ../prod.yml")
+	_, err = NewFromFile(path.Join(dir, "prod.yml"), configTestVars, map[string]interface{}{}, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Cyclic include of manifests")
+}
+
+func TestConfigNewFromFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("compose.yml", `namespace: myapp
+volumes:
+  data: {}
+containers:
+  app:
+    image: quay.io/myapp:{{ .version.myapp }}
+    labels:
+      role: app
+    env:
+      FOO: foo
+    volumes: data:/data
+    add_host: ["a:127.0.0.1", "b:127.0.0.1"]`)
+	writeFile("override.yml", `containers:
+  app:
+    labels:
+      env: {{ .env }}
+    volumes:
+      - data:/data
+      - ./conf:/etc/app
+    add_host: ["c:127.0.0.1"]`)
+
+	files := []string{path.Join(dir, "compose.yml"), path.Join(dir, "override.yml")}
+	vars := map[string]interface{}{"version": configTestVars["version"], "env": "staging"}
+
+	config, err := NewFromFiles(files, vars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := config.Containers["app"]
+	assert.Equal(t, "myapp", config.Namespace)
+	assert.Equal(t, "quay.io/myapp:1.9.2", *app.Image)
+	assert.Equal(t, StringMap{"role": "app", "env": "staging"}, app.Labels)
+	assert.Equal(t, StringMap{"FOO": "foo"}, app.Env)
+	assert.Equal(t, Strings{"myapp.data:/data", path.Join(dir, "conf") + ":/etc/app"}, app.Volumes)
+	assert.Equal(t, Strings{"c:127.0.0.1"}, app.AddHost)
+
+	_, err = NewFromFiles(append(files, path.Join(dir, "missing.yml")), vars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, fmt.Sprintf("Failed to open config file %s, error: open %s: no such file or directory",
+		path.Join(dir, "missing.yml"), path.Join(dir, "missing.yml")))
+}
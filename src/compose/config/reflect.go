@@ -30,6 +30,10 @@ var compareSkipFields = []string{
 	"NetworkDisabled",
 	"State",
 	"KeepVolumes",
+	"MigrateVolumes",
+	"UpdateParallelism",
+	"Ready",
+	"EnvFile", // contents are merged to env and compared there
 
 	// aliases
 	"Command",
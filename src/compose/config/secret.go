@@ -0,0 +1,267 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// secretHashPrefix marks env values that are salted hashes of secrets,
+// e.g. secret:sha256:<salt>:<hash>
+const secretHashPrefix = "secret:sha256:"
+
+// IsSecretEnv returns true if the env var is marked as secret by secret_env,
+// which lists var names or shell patterns, e.g. DB_PASSWORD or *_TOKEN
+func (config *Container) IsSecretEnv(key string) bool {
+	for _, pattern := range config.SecretEnv {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// HashSecrets returns a copy of the spec in which values of secret env vars
+// and contents of secrets are replaced with salted hashes. The spec is stored
+// in the container label in this form, so the values are not revealed by 'docker inspect'.
+func (config *Container) HashSecrets() *Container {
+	hashed, _ := hashSecretsPair(config, &Container{})
+	return hashed
+}
+
+// RestoreSecrets returns a copy of the spec read from the container label in which
+// hashed values of secret env vars are replaced with the actual ones taken from
+// the container env, given in the "KEY=value" form. It is needed to run the
+// container again from its label, e.g. on rollback.
+func (config *Container) RestoreSecrets(env []string) *Container {
+	restored := *config
+	restored.Env = copyEnv(config.Env)
+
+	for key, value := range config.Env {
+		if !isSecretHash(value) {
+			continue
+		}
+		for _, kv := range env {
+			if strings.HasPrefix(kv, key+"=") {
+				restored.Env[key] = strings.TrimPrefix(kv, key+"=")
+				break
+			}
+		}
+	}
+
+	return &restored
+}
+
+// ResolveSecrets returns a copy of the hashed spec in which hashed values of secret env
+// vars and contents of secrets are taken from the source spec, usually the one of the
+// current manifest, matched by name. Values are checked against the hashes, so an error
+// is returned if a value is unknown or differs from the one the spec was made with.
+func (config *Container) ResolveSecrets(source *Container) (*Container, error) {
+	if source == nil {
+		source = &Container{}
+	}
+
+	resolved := *config
+	resolved.Env = copyEnv(config.Env)
+
+	for key, value := range config.Env {
+		if !isSecretHash(value) {
+			continue
+		}
+		actual, ok := source.Env[key]
+		if !ok || isSecretHash(actual) {
+			return nil, fmt.Errorf("value of secret env %s is unknown", key)
+		}
+		if hashSecret(actual, secretSalt(value)) != value {
+			return nil, fmt.Errorf("value of secret env %s has changed", key)
+		}
+		resolved.Env[key] = actual
+	}
+
+	if config.Secrets != nil {
+		resolved.Secrets = Secrets{}
+	}
+	for name, secret := range config.Secrets {
+		if secret.Value != "" || secret.Hash == "" {
+			resolved.Secrets[name] = secret
+			continue
+		}
+		actual, ok := source.Secrets[name]
+		if !ok || actual.Value == "" {
+			return nil, fmt.Errorf("content of secret %s is unknown", name)
+		}
+		if hashSecret(actual.Value, secretSalt(secret.Hash)) != secret.Hash {
+			return nil, fmt.Errorf("content of secret %s has changed", name)
+		}
+		copied := *secret
+		copied.Value = actual.Value
+		resolved.Secrets[name] = &copied
+	}
+
+	return &resolved, nil
+}
+
+// HasHashedSecrets returns true if values of some secret env vars or contents
+// of some secrets of the spec are only known by their hashes
+func (config *Container) HasHashedSecrets() bool {
+	for _, value := range config.Env {
+		if isSecretHash(value) {
+			return true
+		}
+	}
+	for _, secret := range config.Secrets {
+		if secret.Value == "" && secret.Hash != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hashSecretsPair returns copies of both specs in which values of env vars that
+// are secret in any of them and contents of secrets are replaced with salted hashes.
+// The same salt is used for the value in both specs, reusing the one of a value that
+// is already hashed, so the hashes can be compared instead of values.
+func hashSecretsPair(a, b *Container) (*Container, *Container) {
+	if len(a.SecretEnv) == 0 && len(b.SecretEnv) == 0 && len(a.Secrets) == 0 && len(b.Secrets) == 0 {
+		return a, b
+	}
+
+	ah, bh := *a, *b
+	ah.Env, bh.Env = copyEnv(a.Env), copyEnv(b.Env)
+	ah.Secrets, bh.Secrets = hashSecretFiles(a.Secrets, b.Secrets)
+
+	keys := map[string]struct{}{}
+	for key := range a.Env {
+		keys[key] = struct{}{}
+	}
+	for key := range b.Env {
+		keys[key] = struct{}{}
+	}
+
+	for key := range keys {
+		if !a.IsSecretEnv(key) && !b.IsSecretEnv(key) {
+			continue
+		}
+
+		av, aok := a.Env[key]
+		bv, bok := b.Env[key]
+
+		salt := secretSalt(bv)
+		if salt == "" {
+			salt = secretSalt(av)
+		}
+		if salt == "" {
+			salt = newSecretSalt()
+		}
+
+		if aok && !isSecretHash(av) {
+			ah.Env[key] = hashSecret(av, salt)
+		}
+		if bok && !isSecretHash(bv) {
+			bh.Env[key] = hashSecret(bv, salt)
+		}
+	}
+
+	return &ah, &bh
+}
+
+// hashSecretFiles returns copies of both collections of secrets, which keep
+// only targets, modes and salted hashes of contents, the same way as hashSecretsPair does
+func hashSecretFiles(a, b Secrets) (Secrets, Secrets) {
+	hash := func(secret *Secret, salt string) *Secret {
+		hashed := &Secret{Target: secret.Target, Mode: secret.Mode, Hash: secret.Hash}
+		if hashed.Hash == "" {
+			hashed.Hash = hashSecret(secret.Value, salt)
+		}
+		return hashed
+	}
+
+	var ah, bh Secrets
+	if a != nil {
+		ah = Secrets{}
+	}
+	if b != nil {
+		bh = Secrets{}
+	}
+
+	for name, as := range a {
+		bs, ok := b[name]
+
+		salt := ""
+		if ok {
+			salt = secretSalt(bs.Hash)
+		}
+		if salt == "" {
+			salt = secretSalt(as.Hash)
+		}
+		if salt == "" {
+			salt = newSecretSalt()
+		}
+
+		ah[name] = hash(as, salt)
+		if ok {
+			bh[name] = hash(bs, salt)
+		}
+	}
+	for name, bs := range b {
+		if _, ok := a[name]; !ok {
+			bh[name] = hash(bs, newSecretSalt())
+		}
+	}
+
+	return ah, bh
+}
+
+func copyEnv(env StringMap) StringMap {
+	if env == nil {
+		return nil
+	}
+	copied := StringMap{}
+	for key, value := range env {
+		copied[key] = value
+	}
+	return copied
+}
+
+func isSecretHash(value string) bool {
+	return strings.HasPrefix(value, secretHashPrefix)
+}
+
+func secretSalt(value string) string {
+	if !isSecretHash(value) {
+		return ""
+	}
+	return strings.SplitN(strings.TrimPrefix(value, secretHashPrefix), ":", 2)[0]
+}
+
+func newSecretSalt() string {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(salt)
+}
+
+func hashSecret(value, salt string) string {
+	sum := sha256.Sum256([]byte(salt + value))
+	return secretHashPrefix + salt + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigIsSecretEnv(t *testing.T) {
+	c := &Container{SecretEnv: Strings{"DB_PASSWORD", "*_TOKEN"}}
+
+	assert.True(t, c.IsSecretEnv("DB_PASSWORD"))
+	assert.True(t, c.IsSecretEnv("GITHUB_TOKEN"))
+	assert.False(t, c.IsSecretEnv("DB_USER"))
+	assert.False(t, (&Container{}).IsSecretEnv("DB_PASSWORD"))
+}
+
+func TestConfigHashSecrets(t *testing.T) {
+	c := &Container{
+		Env:       StringMap{"DB_USER": "app", "DB_PASSWORD": "qwerty"},
+		SecretEnv: Strings{"DB_PASSWORD"},
+	}
+
+	hashed := c.HashSecrets()
+	assert.Equal(t, "app", hashed.Env["DB_USER"])
+	assert.True(t, strings.HasPrefix(hashed.Env["DB_PASSWORD"], "secret:sha256:"))
+	assert.NotContains(t, hashed.Env["DB_PASSWORD"], "qwerty")
+
+	// the original spec is untouched
+	assert.Equal(t, "qwerty", c.Env["DB_PASSWORD"])
+
+	// salts are random, equal values are not revealed by equal hashes
+	assert.NotEqual(t, hashed.Env["DB_PASSWORD"], c.HashSecrets().Env["DB_PASSWORD"])
+
+	// but the spec is still equal to the hashed one
+	assert.True(t, c.IsEqualTo(hashed))
+
+	changed := &Container{
+		Env:       StringMap{"DB_USER": "app", "DB_PASSWORD": "123456"},
+		SecretEnv: Strings{"DB_PASSWORD"},
+	}
+	assert.False(t, changed.IsEqualTo(hashed))
+
+	diffs, err := changed.Diff(hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, diffs, 1)
+	assert.Equal(t, "env", diffs[0].Field)
+	assert.NotContains(t, diffs[0].New, "123456")
+}
+
+func TestConfigDiffSecretsNotHashed(t *testing.T) {
+	c1 := &Container{Env: StringMap{"DB_PASSWORD": "qwerty"}, SecretEnv: Strings{"DB_PASSWORD"}}
+	c2 := &Container{Env: StringMap{"DB_PASSWORD": "123456"}}
+
+	diffs, err := c1.Diff(c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, diffs, 2)
+	assert.Equal(t, "env", diffs[0].Field)
+	assert.NotContains(t, diffs[0].Old, "123456")
+	assert.NotContains(t, diffs[0].New, "qwerty")
+	assert.Equal(t, "secret_env", diffs[1].Field)
+}
+
+func TestConfigRestoreSecrets(t *testing.T) {
+	c := &Container{
+		Env:       StringMap{"DB_USER": "app", "DB_PASSWORD": "qwerty"},
+		SecretEnv: Strings{"DB_PASSWORD"},
+	}
+
+	restored := c.HashSecrets().RestoreSecrets([]string{"DB_USER=app", "DB_PASSWORD=qwerty", "PATH=/bin"})
+	assert.Equal(t, c.Env, restored.Env)
+}
+
+func TestConfigHashSecretFiles(t *testing.T) {
+	c := &Container{
+		Secrets: Secrets{
+			"db_password": &Secret{File: "/secrets/db", Value: "qwerty", Target: "/run/secrets/db_password"},
+		},
+	}
+
+	hashed := c.HashSecrets()
+	assert.Equal(t, "/run/secrets/db_password", hashed.Secrets["db_password"].Target)
+	assert.Empty(t, hashed.Secrets["db_password"].Value)
+	assert.Empty(t, hashed.Secrets["db_password"].File)
+	assert.True(t, strings.HasPrefix(hashed.Secrets["db_password"].Hash, "secret:sha256:"))
+	assert.Equal(t, "qwerty", c.Secrets["db_password"].Value)
+
+	assert.True(t, c.IsEqualTo(hashed))
+
+	changed := &Container{
+		Secrets: Secrets{
+			"db_password": &Secret{File: "/secrets/db", Value: "123456", Target: "/run/secrets/db_password"},
+		},
+	}
+	assert.False(t, changed.IsEqualTo(hashed))
+}
+
+func TestConfigResolveSecrets(t *testing.T) {
+	c := &Container{
+		Env:       StringMap{"DB_USER": "app", "DB_PASSWORD": "qwerty"},
+		SecretEnv: Strings{"DB_PASSWORD"},
+		Secrets: Secrets{
+			"db_key": &Secret{Value: "key", Target: "/run/secrets/db_key"},
+		},
+	}
+
+	hashed := c.HashSecrets()
+	assert.True(t, hashed.HasHashedSecrets())
+	assert.False(t, c.HasHashedSecrets())
+
+	resolved, err := hashed.ResolveSecrets(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, resolved.HasHashedSecrets())
+	assert.Equal(t, c.Env, resolved.Env)
+	assert.Equal(t, "key", resolved.Secrets["db_key"].Value)
+	assert.Equal(t, "/run/secrets/db_key", resolved.Secrets["db_key"].Target)
+	assert.Empty(t, hashed.Secrets["db_key"].Value)
+
+	changed := &Container{
+		Env:     StringMap{"DB_PASSWORD": "qwerty"},
+		Secrets: Secrets{"db_key": &Secret{Value: "another"}},
+	}
+	_, err = hashed.ResolveSecrets(changed)
+	assert.EqualError(t, err, "content of secret db_key has changed")
+
+	_, err = hashed.ResolveSecrets(&Container{Secrets: c.Secrets})
+	assert.EqualError(t, err, "value of secret env DB_PASSWORD is unknown")
+}
@@ -20,28 +20,37 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // UnmarshalYAML unserialize Config object form YAML
 // It supports compatibility with docker-compose YAML spec where containers map is specified
 // on the first level. rocker-compose provides extra level for global properties such as 'namespace'
-// This function fallbacks to the docker-compose format if 'namespace' key was not found on the
-// first level.
+// This function fallbacks to the docker-compose format if none of 'namespace', 'containers',
+// 'networks', 'volumes' or 'include' keys was found on the first level.
 func (config *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	// compatibiliy with docker-compose format, if namespace is not specified,
-	// we think it is docker-compose format
+	// we think it is docker-compose format; manifests that only include
+	// others or are included themselves may have no namespace though
+	include := Strings{}
 	c := &struct {
 		Namespace  *string
 		Containers *map[string]*Container
+		Networks   *map[string]*Network
+		Volumes    *map[string]*Volume
+		Include    *Strings
 	}{
 		&config.Namespace,
 		&config.Containers,
+		&config.Networks,
+		&config.Volumes,
+		&include,
 	}
 	if err := unmarshal(c); err != nil {
 		return err
 	}
 	// parse containers only, if namespace is empty, we will deal with it later
-	if *c.Namespace == "" {
+	if *c.Namespace == "" && config.Containers == nil && config.Networks == nil && config.Volumes == nil && len(include) == 0 {
 		if err := unmarshal(&c.Containers); err != nil {
 			return err
 		}
@@ -177,6 +186,41 @@ func (cmd *Cmd) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
 	return nil
 }
 
+// UnmarshalYAML unserialize HealthcheckTest object from YAML
+// If string is given, then it adds 'CMD-SHELL' prefix to a command,
+// if a list is given without a test type, then it adds 'CMD' prefix.
+func (test *HealthcheckTest) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+	parts, err := stringSliceMaybeString([]string{"CMD-SHELL"}, unmarshal)
+	if err != nil {
+		return err
+	}
+	if len(parts) > 0 && parts[0] != "CMD" && parts[0] != "CMD-SHELL" && parts[0] != "NONE" {
+		parts = append([]string{"CMD"}, parts...)
+	}
+	*test = (HealthcheckTest)(parts)
+
+	return nil
+}
+
+// UnmarshalYAML unserialize Duration object from YAML
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+	value, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = (Duration)(value)
+	return nil
+}
+
+// MarshalYAML serialize Duration object to YAML
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return (time.Duration)(d).String(), nil
+}
+
 // UnmarshalYAML unserialize Net object from YAML
 func (n *Net) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var str string
@@ -196,6 +240,34 @@ func (n *Net) MarshalYAML() (interface{}, error) {
 	return n.String(), nil
 }
 
+// UnmarshalYAML unserialize Extends object from YAML
+// Either a container name or a map with file and container can be given
+func (e *Extends) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*e = Extends{Container: name}
+		return nil
+	}
+
+	type extends Extends
+	var value extends
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	*e = (Extends)(value)
+	return nil
+}
+
+// MarshalYAML serialize Extends object to YAML
+// A container of the same manifest is written as its name
+func (e Extends) MarshalYAML() (interface{}, error) {
+	if e.File == "" {
+		return e.Container, nil
+	}
+	type extends Extends
+	return (extends)(e), nil
+}
+
 // UnmarshalYAML unserialize slice of ContainerName objects from YAML
 // Either single value or array can be given. Single 'value' casts to array{'value'}
 func (v *ContainerNames) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -262,6 +334,35 @@ func (v *Strings) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// UnmarshalYAML unserialize Networks object from YAML
+// Either map of network names to endpoint settings or a list of names can be given,
+// as well as a single name.
+func (v *Networks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value map[string]*NetworkEndpoint
+
+	if err := unmarshal(&value); err != nil {
+		names, err := stringSliceMaybeString([]string{}, unmarshal)
+		if err != nil {
+			return err
+		}
+		value = map[string]*NetworkEndpoint{}
+		for _, name := range names {
+			value[name] = nil
+		}
+	}
+
+	// endpoint settings are optional, e.g. "backend:" with no value
+	for name, endpoint := range value {
+		if endpoint == nil {
+			value[name] = &NetworkEndpoint{}
+		}
+	}
+
+	*v = (Networks)(value)
+
+	return nil
+}
+
 // UnmarshalYAML unserialize map[string]string objects from YAML
 // Map can be also specified as string "key=val key2=val2"
 // and also as array of strings []string{"key=val", "key2=val2"}
@@ -177,6 +177,35 @@ func TestYamlNet(t *testing.T) {
 	}
 }
 
+func TestYamlHealthcheck(t *testing.T) {
+	test := &yamlTestCases{
+		map[string]string{
+			"healthcheck:\n  test: curl -f localhost":           "healthcheck:\n  test:\n  - CMD-SHELL\n  - curl -f localhost",
+			"healthcheck:\n  test: [curl, -f, localhost]":       "healthcheck:\n  test:\n  - CMD\n  - curl\n  - -f\n  - localhost",
+			"healthcheck:\n  test: [CMD-SHELL, curl localhost]": "healthcheck:\n  test:\n  - CMD-SHELL\n  - curl localhost",
+			"healthcheck:\n  test: [NONE]":                      "healthcheck:\n  test:\n  - NONE",
+			"healthcheck:\n  interval: 90s\n  start_period: 1m": "healthcheck:\n  interval: 1m30s\n  start_period: 1m0s",
+		},
+	}
+	if err := test.run(t); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestYamlReady(t *testing.T) {
+	test := &yamlTestCases{
+		map[string]string{
+			"ready:\n  tcp: 5432":                 "ready:\n  tcp: 5432",
+			"ready:\n  http:\n    port: 8080":     "ready:\n  http:\n    port: 8080",
+			"ready:\n  exec: pg_isready":          "ready:\n  exec:\n  - /bin/sh\n  - -c\n  - pg_isready",
+			"ready:\n  tcp: 80\n  timeout: 500ms": "ready:\n  tcp: 80\n  timeout: 500ms",
+		},
+	}
+	if err := test.run(t); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestYamlVolumesFrom(t *testing.T) {
 	test := &yamlTestCases{
 		map[string]string{
@@ -17,8 +17,10 @@
 package compose
 
 import (
+	"fmt"
 	"github.com/grammarly/rocker-compose/src/compose/config"
 	"github.com/grammarly/rocker-compose/src/util"
+	"strconv"
 	"strings"
 	"time"
 
@@ -143,57 +145,78 @@ func (a *Container) IsEqualTo(b *Container) bool {
 		return false
 	}
 
+	diffs := a.Differences(b)
+	for _, d := range diffs {
+		log.Debugf("Comparing '%s' and '%s': found difference in '%s' (was %s became %s)",
+			a.Name.String(),
+			b.Name.String(),
+			d.Field,
+			d.Old,
+			d.New)
+	}
+
+	return len(diffs) == 0
+}
+
+// CanUpdateLive returns true if the existing container differs from the spec
+// only by properties that can be changed without recreating it
+func (a *Container) CanUpdateLive(b *Container) bool {
+	return a.Config.CanUpdateLive(a.Differences(b))
+}
+
+// Differences returns the list of all properties in which current (expected) container
+// differs from the given (actual) one. Besides the spec it compares image version,
+// image id, exit code of containers that should run once and state.
+func (a *Container) Differences(b *Container) (diffs []config.FieldDiff) {
 	// check configuration
-	if !a.Config.IsEqualTo(b.Config) {
-		log.Debugf("Comparing '%s' and '%s': found difference in '%s'",
+	configDiffs, err := a.Config.Diff(b.Config)
+	if err != nil {
+		// consider the whole spec changed if we cannot tell what exactly
+		log.Debugf("Comparing '%s' and '%s': failed to compare spec, error: %s",
 			a.Name.String(),
 			b.Name.String(),
-			a.Config.LastCompareField())
-		return false
+			err)
+		configDiffs = []config.FieldDiff{{Field: "config", Old: "?", New: "?"}}
 	}
+	diffs = append(diffs, configDiffs...)
 
 	// check image version
 	if a.Image != nil && !a.Image.Contains(b.Image) {
-		log.Debugf("Comparing '%s' and '%s': image version '%s' is not satisfied (was %s should satisfy %s)",
-			a.Name.String(),
-			b.Name.String(),
-			a.Image,
-			b.Image,
-			a.Image)
-		return false
+		diffs = append(diffs, config.FieldDiff{
+			Field: "image",
+			Old:   b.Image.String(),
+			New:   a.Image.String(),
+		})
 	}
 
 	// check image id
 	if a.ImageID != "" && b.ImageID != "" && a.ImageID != b.ImageID {
-		log.Debugf("Comparing '%s' and '%s': image '%s' updated (was %.12s became %.12s)",
-			a.Name.String(),
-			b.Name.String(),
-			a.Image,
-			b.ImageID,
-			a.ImageID)
-		return false
+		diffs = append(diffs, config.FieldDiff{
+			Field: "image_id",
+			Old:   b.ImageID,
+			New:   a.ImageID,
+		})
 	}
 
 	// One of exit codes is always '0' since once of containers (a or b) is always loaded from config
 	if a.Config.State.IsRan() && a.State.ExitCode+b.State.ExitCode > 0 {
-		log.Debugf("Comparing '%s' and '%s': container should run once, but previous exit code was %d",
-			a.Name.String(),
-			b.Name.String(),
-			a.State.ExitCode+b.State.ExitCode)
-		return false
+		diffs = append(diffs, config.FieldDiff{
+			Field: "exit_code",
+			Old:   strconv.Itoa(a.State.ExitCode + b.State.ExitCode),
+			New:   "0",
+		})
 	}
 
 	// check state
 	if !a.State.IsEqualState(b.State) {
-		log.Debugf("Comparing '%s' and '%s': found difference in state: Running: %t != %t",
-			a.Name.String(),
-			b.Name.String(),
-			a.State.Running,
-			b.State.Running)
-		return false
+		diffs = append(diffs, config.FieldDiff{
+			Field: "state",
+			Old:   b.State.String(),
+			New:   a.State.String(),
+		})
 	}
 
-	return true
+	return diffs
 }
 
 // IsEqualState returns true if current and given containers have the same state
@@ -201,11 +224,21 @@ func (a *ContainerState) IsEqualState(b *ContainerState) bool {
 	return a.Running == b.Running
 }
 
+// String returns the short human readable representation of the state
+func (a *ContainerState) String() string {
+	if a.Running {
+		return "running"
+	}
+	return "stopped"
+}
+
 // CreateContainerOptions returns create configuration eatable by go-dockerclient
 func (a *Container) CreateContainerOptions() (*docker.CreateContainerOptions, error) {
 	apiConfig := a.Config.GetAPIConfig()
 
-	yamlData, err := yaml.Marshal(a.Config)
+	// values of secret env vars are only given to docker in the env,
+	// the label keeps their hashes to detect changes
+	yamlData, err := yaml.Marshal(a.Config.HashSecrets())
 	if err != nil {
 		return nil, err
 	}
@@ -222,8 +255,44 @@ func (a *Container) CreateContainerOptions() (*docker.CreateContainerOptions, er
 	apiConfig.Image = a.Image.String()
 
 	return &docker.CreateContainerOptions{
-		Name:       a.Name.String(),
-		Config:     apiConfig,
-		HostConfig: a.Config.GetAPIHostConfig(),
+		Name:             a.Name.String(),
+		Config:           apiConfig,
+		HostConfig:       a.Config.GetAPIHostConfig(),
+		NetworkingConfig: a.Config.GetAPINetworkingConfig(),
 	}, nil
 }
+
+// specWithSecrets returns the spec of the existing container read from its label
+// with actual values of secrets, so the container can be run again. Values of secret
+// env vars are taken from the container env, while contents of secrets are not kept
+// on the docker host, so they are taken from the manifest, if there is one.
+func (a *Container) specWithSecrets(manifest *config.Config) (*config.Container, error) {
+	if a.Config == nil {
+		return nil, fmt.Errorf("its spec is unknown")
+	}
+
+	spec := a.Config
+	if a.container != nil && a.container.Config != nil {
+		spec = spec.RestoreSecrets(a.container.Config.Env)
+	}
+	if !spec.HasHashedSecrets() {
+		return spec, nil
+	}
+
+	return spec.ResolveSecrets(manifestSpec(manifest, a.Name))
+}
+
+// containersByName implements sort.Interface to sort containers by name
+type containersByName []*Container
+
+func (c containersByName) Len() int {
+	return len(c)
+}
+
+func (c containersByName) Less(i, j int) bool {
+	return c[i].Name.String() < c[j].Name.String()
+}
+
+func (c containersByName) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
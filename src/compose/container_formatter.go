@@ -29,10 +29,16 @@ type formatter struct {
 // NewContainerFormatter returns an object that is given to logrus to better format
 // contaienr output
 func NewContainerFormatter(container *Container, level log.Level) log.Formatter {
+	return newContainerFormatter(container, level, log.StandardLogger().Formatter)
+}
+
+// newContainerFormatter makes the container formatter with the given delegate,
+// e.g. 'rocker-compose logs -json' formats lines as JSON regardless of the log format
+func newContainerFormatter(container *Container, level log.Level, delegate log.Formatter) log.Formatter {
 	return &formatter{
 		container: container,
 		level:     level,
-		delegate:  log.StandardLogger().Formatter,
+		delegate:  delegate,
 	}
 }
 
@@ -41,6 +47,7 @@ func (f *formatter) Format(entry *log.Entry) ([]byte, error) {
 	e := entry.WithFields(log.Fields{
 		"container": f.container.Name.String(),
 	})
+	e.Time = entry.Time
 	e.Message = entry.Message
 	e.Level = f.level
 	return f.delegate.Format(e)
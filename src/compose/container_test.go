@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker/src/imagename"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -130,3 +131,39 @@ func TestNewFromDocker(t *testing.T) {
 	assert.True(t, compareResult,
 		"container spec converted from API should be equal to one fetched from config file, failed on field: %s", cfg.Containers["main"].LastCompareField())
 }
+
+func TestCreateContainerOptionsSecretEnv(t *testing.T) {
+	container := newContainer("myapp", "main")
+	container.Image = imagename.NewFromString("quay.io/myapp:1.9.2")
+	container.Config.Env = config.StringMap{"DB_PASSWORD": "qwerty"}
+	container.Config.SecretEnv = config.Strings{"DB_PASSWORD"}
+
+	opts, err := container.CreateContainerOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, opts.Config.Env, "DB_PASSWORD=qwerty")
+	assert.NotContains(t, opts.Config.Labels["rocker-compose-config"], "qwerty")
+
+	apiContainer := &docker.Container{
+		Config: &docker.Config{
+			Env:    opts.Config.Env,
+			Labels: opts.Config.Labels,
+		},
+		Name: "/myapp.main",
+	}
+
+	existing, err := NewContainerFromDocker(apiContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, container.Config.IsEqualTo(existing.Config))
+
+	spec, err := existing.specWithSecrets(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "qwerty", spec.Env["DB_PASSWORD"])
+}
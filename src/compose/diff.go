@@ -19,6 +19,7 @@ package compose
 import (
 	"fmt"
 	"github.com/grammarly/rocker-compose/src/compose/config"
+	"sort"
 )
 
 // Diff describes a comparison functionality of two container sets: expected and actual
@@ -35,6 +36,7 @@ type Diff interface {
 type graph struct {
 	ns           string
 	dependencies map[*Container][]*dependency
+	blueGreen    bool
 }
 
 // single dependency (external - means not in our namespace)
@@ -44,6 +46,12 @@ type dependency struct {
 	waitForIt bool
 }
 
+// recreation of a container that belongs to a rolling update group
+type recreation struct {
+	container *Container
+	action    Action
+}
+
 // NewDiff returns an implementation of Diff object
 func NewDiff(ns string) Diff {
 	return &graph{
@@ -52,6 +60,17 @@ func NewDiff(ns string) Diff {
 	}
 }
 
+// NewBlueGreenDiff returns an implementation of Diff object which replaces changed
+// running containers without a downtime window, see NewReplaceContainerAction.
+// Containers that cannot run next to their old version are recreated as usual.
+func NewBlueGreenDiff(ns string) Diff {
+	return &graph{
+		ns:           ns,
+		dependencies: make(map[*Container][]*dependency),
+		blueGreen:    true,
+	}
+}
+
 // Diff compares 'expected' and 'actual' state by detecting changes and building
 // a gependency graph, and returns an action list that is needed to transition
 // from 'actual' state to 'expected' one.
@@ -147,6 +166,51 @@ func resolveDependencies(ns string, expected []*Container, actual []*Container,
 	return
 }
 
+// SelectContainers returns given containers of the namespace together with all their
+// transitive dependencies (volumes_from, links, net and wait_for) from the 'expected' list.
+// Dependencies from other namespaces are not included, they are looked up in 'actual'.
+func SelectContainers(ns string, names []string, expected []*Container, actual []*Container) ([]*Container, error) {
+	var (
+		selected = []*Container{}
+		visited  = map[*Container]bool{}
+		queue    = []*Container{}
+	)
+
+	for _, name := range names {
+		containerName := config.NewContainerNameFromString(name)
+		containerName.DefaultNamespace(ns)
+
+		container := find(expected, containerName)
+		if container == nil {
+			return nil, fmt.Errorf("Cannot find container %s in the manifest", containerName)
+		}
+		queue = append(queue, container)
+	}
+
+	for len(queue) > 0 {
+		container := queue[0]
+		queue = queue[1:]
+
+		if visited[container] {
+			continue
+		}
+		visited[container] = true
+		selected = append(selected, container)
+
+		dependencies, err := resolveDependencies(ns, expected, actual, container)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range dependencies {
+			if !dep.external {
+				queue = append(queue, dep.container)
+			}
+		}
+	}
+
+	return selected, nil
+}
+
 func listContainersToRemove(ns string, expected []*Container, actual []*Container) (res []Action) {
 	for _, a := range actual {
 		if a.Name.Namespace == ns {
@@ -171,6 +235,9 @@ func (g *graph) buildExecutionPlan(actual []*Container) (res []Action) {
 	for len(visited) < len(g.dependencies) {
 		var step = []Action{}
 
+		// recreations of containers extending the same parent, see 'update_parallelism'
+		var rolling = map[string][]*recreation{}
+
 	nextDependency:
 		for container, deps := range g.dependencies {
 			// if dependency is already visited - skip it
@@ -206,6 +273,15 @@ func (g *graph) buildExecutionPlan(actual []*Container) (res []Action) {
 			// comparing dependency with current state
 			for _, actualContainer := range actual {
 				if container.IsSameKind(actualContainer) {
+					// only resource limits or restart policy changed - update container in place
+					if !restart && container.Name.Namespace == g.ns && container.CanUpdateLive(actualContainer) {
+						step = append(step, NewStepAction(false,
+							NewStepAction(true, depActions...),
+							NewUpdateContainerAction(container, actualContainer),
+						))
+						continue nextDependency
+					}
+
 					//in configuration was changed or restart forced by dependency - recreate container
 					if !container.IsEqualTo(actualContainer) || restart {
 						restartActions := []Action{
@@ -214,6 +290,20 @@ func (g *graph) buildExecutionPlan(actual []*Container) (res []Action) {
 							NewRunContainerAction(container),
 						}
 
+						if g.blueGreen && canReplace(container) {
+							restartActions = []Action{
+								NewStepAction(true, depActions...),
+								NewReplaceContainerAction(container, actualContainer),
+							}
+						}
+
+						if container.Config.MigrateVolumes != nil && *container.Config.MigrateVolumes {
+							restartActions = []Action{
+								NewStepAction(true, depActions...),
+								NewMigrateContainerAction(container, actualContainer),
+							}
+						}
+
 						// in recovery mode we have to ensure containers are started
 						if container.Name.Namespace != g.ns {
 							restartActions = []Action{
@@ -222,7 +312,14 @@ func (g *graph) buildExecutionPlan(actual []*Container) (res []Action) {
 							}
 						}
 
-						step = append(step, NewStepAction(false, restartActions...))
+						if group := rollingGroup(container); group != "" && container.Name.Namespace == g.ns {
+							rolling[group] = append(rolling[group], &recreation{
+								container: container,
+								action:    NewStepAction(false, restartActions...),
+							})
+						} else {
+							step = append(step, NewStepAction(false, restartActions...))
+						}
 
 						// mark container as recreated
 						restarted[container] = struct{}{}
@@ -242,6 +339,10 @@ func (g *graph) buildExecutionPlan(actual []*Container) (res []Action) {
 			))
 		}
 
+		for _, recreations := range rolling {
+			step = append(step, newRollingUpdateStep(recreations))
+		}
+
 		//finalize step
 		for container, visit := range visited {
 			if !visit {
@@ -255,6 +356,80 @@ func (g *graph) buildExecutionPlan(actual []*Container) (res []Action) {
 	return
 }
 
+// canReplace returns true if the new version of the container can be started next to
+// the old one: only running containers that do not bind fixed host ports
+// and do not have static addresses in user-defined networks
+func canReplace(container *Container) bool {
+	if !container.State.Running {
+		return false
+	}
+	for _, port := range container.Config.Ports {
+		if port.HostPort != "" {
+			return false
+		}
+	}
+	for _, endpoint := range container.Config.Networks {
+		if endpoint != nil && (endpoint.IPv4Address != "" || endpoint.IPv6Address != "") {
+			return false
+		}
+	}
+	return true
+}
+
+// rollingGroup returns the name of the rolling update group of the container,
+// which is the parent it extends from, or an empty string if it is not rolled out by batches
+func rollingGroup(container *Container) string {
+	if container.Config.UpdateParallelism == nil || *container.Config.UpdateParallelism <= 0 || container.Config.Extends == nil {
+		return ""
+	}
+	return container.Config.Extends.String()
+}
+
+// newRollingUpdateStep makes a step that recreates containers of the same group
+// by batches of 'update_parallelism' size. Batches run one by one, so the rollout
+// stops on the first container that failed to start or exited during the '--wait' period.
+func newRollingUpdateStep(recreations []*recreation) Action {
+	sort.Sort(recreationsByName(recreations))
+
+	// the smallest batch wins if group members disagree
+	parallelism := *recreations[0].container.Config.UpdateParallelism
+	for _, r := range recreations {
+		if n := *r.container.Config.UpdateParallelism; n < parallelism {
+			parallelism = n
+		}
+	}
+
+	batches := []Action{}
+	for i := 0; i < len(recreations); i += parallelism {
+		end := i + parallelism
+		if end > len(recreations) {
+			end = len(recreations)
+		}
+		batch := []Action{}
+		for _, r := range recreations[i:end] {
+			batch = append(batch, r.action)
+		}
+		batches = append(batches, NewStepAction(true, batch...))
+	}
+
+	return NewStepAction(false, batches...)
+}
+
+// recreationsByName implements sort.Interface to sort recreations by container name
+type recreationsByName []*recreation
+
+func (r recreationsByName) Len() int {
+	return len(r)
+}
+
+func (r recreationsByName) Less(i, j int) bool {
+	return r[i].container.Name.String() < r[j].container.Name.String()
+}
+
+func (r recreationsByName) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
 func find(containers []*Container, name *config.ContainerName) *Container {
 	for _, c := range containers {
 		if c.Name.IsEqualTo(name) {
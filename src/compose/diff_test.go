@@ -19,6 +19,7 @@ package compose
 import (
 	"fmt"
 	"github.com/grammarly/rocker-compose/src/compose/config"
+	"io"
 	"testing"
 
 	"github.com/grammarly/rocker/src/imagename"
@@ -282,17 +283,17 @@ func TestDiffForCycles(t *testing.T) {
 func TestDiffDifferentConfig(t *testing.T) {
 	cmp := NewDiff("test")
 	containers := []*Container{}
-	cpusetCpus1 := "0-2"
-	cpusetCpus2 := "0-4"
+	hostname1 := "foo"
+	hostname2 := "bar"
 	c1x := &Container{
 		State:  &ContainerState{Running: true},
 		Name:   &config.ContainerName{Namespace: "test", Name: "1"},
-		Config: &config.Container{CpusetCpus: &cpusetCpus1},
+		Config: &config.Container{Hostname: &hostname1},
 	}
 	c1y := &Container{
 		State:  &ContainerState{Running: true},
 		Name:   &config.ContainerName{Namespace: "test", Name: "1"},
-		Config: &config.Container{CpusetCpus: &cpusetCpus2},
+		Config: &config.Container{Hostname: &hostname2},
 	}
 	containers = append(containers, c1x)
 	actions, _ := cmp.Diff(containers, []*Container{c1y})
@@ -304,6 +305,202 @@ func TestDiffDifferentConfig(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestDiffUpdateInPlace(t *testing.T) {
+	cmp := NewDiff("test")
+	cpusetCpus1 := "0-2"
+	cpusetCpus2 := "0-4"
+	c1 := newContainer("test", "1")
+	c1.Config.CpusetCpus = &cpusetCpus1
+	c1x := newContainer("test", "1")
+	c1x.Config.CpusetCpus = &cpusetCpus2
+	c1x.ID = "abc"
+	c2 := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "1"})
+	c2x := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "1"})
+	actions, _ := cmp.Diff([]*Container{c1, c2}, []*Container{c1x, c2x})
+	mock := clientMock{}
+	mock.On("UpdateContainer", c1).Return(nil)
+	runner := NewDockerClientRunner(&mock)
+	runner.Run(actions)
+	mock.AssertExpectations(t)
+	assert.Equal(t, "abc", c1.ID)
+}
+
+func TestDiffUpdateInPlaceUnset(t *testing.T) {
+	cmp := NewDiff("test")
+	cpusetCpus := "0-2"
+	c1 := newContainer("test", "1")
+	c1x := newContainer("test", "1")
+	c1x.Config.CpusetCpus = &cpusetCpus
+	actions, _ := cmp.Diff([]*Container{c1}, []*Container{c1x})
+	mock := clientMock{}
+	mock.On("RemoveContainer", c1x).Return(nil)
+	mock.On("RunContainer", c1).Return(nil)
+	runner := NewDockerClientRunner(&mock)
+	runner.Run(actions)
+	mock.AssertExpectations(t)
+}
+
+func TestDiffRollingUpdate(t *testing.T) {
+	cmp := NewDiff("test")
+	parallelism := 2
+	expected := []*Container{}
+	actual := []*Container{}
+	for _, name := range []string{"worker_1", "worker_2", "worker_3"} {
+		c := newContainer("test", name)
+		c.Config.Extends = &config.Extends{Container: "worker"}
+		c.Config.UpdateParallelism = &parallelism
+		c.Config.Env = config.StringMap{"VERSION": "2"}
+		expected = append(expected, c)
+		actual = append(actual, newContainer("test", name))
+	}
+	actions, err := cmp.Diff(expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one sync step with batches of two and one containers
+	assert.Len(t, actions, 1)
+	rollout, ok := actions[0].(*stepAction)
+	if !ok {
+		t.Fatalf("expected step action, got %s", actions[0])
+	}
+	assert.False(t, rollout.async)
+	assert.Len(t, rollout.actions, 2)
+	assert.Len(t, rollout.actions[0].(*stepAction).actions, 2)
+
+	// the rollout stops on the first failed batch
+	mock := clientMock{}
+	mock.On("RemoveContainer", actual[0]).Return(nil)
+	mock.On("RemoveContainer", actual[1]).Return(nil)
+	mock.On("RunContainer", expected[0]).Return(fmt.Errorf("exited"))
+	mock.On("RunContainer", expected[1]).Return(nil)
+	runner := NewDockerClientRunner(&mock)
+	assert.Error(t, runner.Run(actions))
+	mock.AssertExpectations(t)
+}
+
+func TestDiffBlueGreen(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Env = config.StringMap{"VERSION": "2"}
+	c1x := newContainer("test", "1")
+	actions, _ := NewBlueGreenDiff("test").Diff([]*Container{c1}, []*Container{c1x})
+
+	client := clientMock{}
+	client.On("RunContainer", mock.Anything).Return(nil)
+	client.On("EnsureContainerState", mock.Anything).Return(nil)
+	client.On("RemoveContainer", c1x).Return(nil)
+	client.On("RenameContainer", mock.Anything, c1.Name).Return(nil)
+	runner := NewDockerClientRunner(&client)
+	assert.NoError(t, runner.Run(actions))
+	client.AssertExpectations(t)
+
+	next := client.Calls[0].Arguments.Get(0).(*Container)
+	assert.Equal(t, "test.1__next", next.Name.String())
+	assert.Equal(t, "RemoveContainer", client.Calls[2].Method)
+	assert.Equal(t, "RenameContainer", client.Calls[3].Method)
+}
+
+func TestDiffBlueGreenFailed(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Env = config.StringMap{"VERSION": "2"}
+	c1x := newContainer("test", "1")
+	actions, _ := NewBlueGreenDiff("test").Diff([]*Container{c1}, []*Container{c1x})
+
+	// the old container should stay, the new one is cleaned up
+	client := clientMock{}
+	client.On("RunContainer", mock.Anything).Return(nil)
+	client.On("EnsureContainerState", mock.Anything).Return(fmt.Errorf("exited"))
+	client.On("RemoveContainer", mock.Anything).Return(nil)
+	runner := NewDockerClientRunner(&client)
+	assert.Error(t, runner.Run(actions))
+	client.AssertExpectations(t)
+
+	removed := client.Calls[2].Arguments.Get(0).(*Container)
+	assert.Equal(t, "test.1__next", removed.Name.String())
+}
+
+func TestDiffBlueGreenHostPorts(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Ports = config.Ports{{Port: "80/tcp", HostPort: "80"}}
+	c1x := newContainer("test", "1")
+	actions, _ := NewBlueGreenDiff("test").Diff([]*Container{c1}, []*Container{c1x})
+
+	// cannot bind the same host port twice, so recreate as usual
+	client := clientMock{}
+	client.On("RemoveContainer", c1x).Return(nil)
+	client.On("RunContainer", c1).Return(nil)
+	runner := NewDockerClientRunner(&client)
+	assert.NoError(t, runner.Run(actions))
+	client.AssertExpectations(t)
+}
+
+func TestDiffBlueGreenStaticAddress(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Networks = config.Networks{"backend": &config.NetworkEndpoint{IPv4Address: "172.20.0.10"}}
+	c1x := newContainer("test", "1")
+	actions, _ := NewBlueGreenDiff("test").Diff([]*Container{c1}, []*Container{c1x})
+
+	// cannot take the same address twice, so recreate as usual
+	client := clientMock{}
+	client.On("RemoveContainer", c1x).Return(nil)
+	client.On("RunContainer", c1).Return(nil)
+	runner := NewDockerClientRunner(&client)
+	assert.NoError(t, runner.Run(actions))
+	client.AssertExpectations(t)
+}
+
+func TestDiffMigrateVolumes(t *testing.T) {
+	migrate := true
+	c1 := newContainer("test", "data")
+	c1.State.Running = false
+	c1.Config.MigrateVolumes = &migrate
+	c1.Config.Volumes = config.Strings{"/data", "/logs"}
+	c1x := newContainer("test", "data")
+	c1x.State.Running = false
+	c1x.Config.Volumes = config.Strings{"/data"}
+	actions, _ := NewDiff("test").Diff([]*Container{c1}, []*Container{c1x})
+
+	client := clientMock{}
+	client.On("RunContainer", mock.Anything).Return(nil)
+	client.On("MigrateVolumes", c1x, mock.Anything).Return(nil)
+	client.On("RemoveContainer", c1x).Return(nil)
+	client.On("RenameContainer", mock.Anything, c1.Name).Return(nil)
+	runner := NewDockerClientRunner(&client)
+	assert.NoError(t, runner.Run(actions))
+	client.AssertExpectations(t)
+
+	// the data is copied to the new container before the old one is removed
+	next := client.Calls[0].Arguments.Get(0).(*Container)
+	assert.Equal(t, "test.data__next", next.Name.String())
+	assert.Equal(t, "MigrateVolumes", client.Calls[1].Method)
+	assert.Equal(t, next, client.Calls[1].Arguments.Get(1))
+	assert.Equal(t, "RemoveContainer", client.Calls[2].Method)
+	assert.Equal(t, "RenameContainer", client.Calls[3].Method)
+}
+
+func TestDiffMigrateVolumesFailed(t *testing.T) {
+	migrate := true
+	c1 := newContainer("test", "data")
+	c1.State.Running = false
+	c1.Config.MigrateVolumes = &migrate
+	c1.Config.Volumes = config.Strings{"/data", "/logs"}
+	c1x := newContainer("test", "data")
+	c1x.State.Running = false
+	actions, _ := NewDiff("test").Diff([]*Container{c1}, []*Container{c1x})
+
+	// the old container with the data should stay, the new one is cleaned up
+	client := clientMock{}
+	client.On("RunContainer", mock.Anything).Return(nil)
+	client.On("MigrateVolumes", c1x, mock.Anything).Return(fmt.Errorf("no space left on device"))
+	client.On("RemoveContainer", mock.Anything).Return(nil)
+	runner := NewDockerClientRunner(&client)
+	assert.Error(t, runner.Run(actions))
+	client.AssertExpectations(t)
+
+	removed := client.Calls[2].Arguments.Get(0).(*Container)
+	assert.Equal(t, "test.data__next", removed.Name.String())
+}
+
 func TestDiffForExternalDependencies(t *testing.T) {
 	cmp := NewDiff("test")
 	containers := []*Container{}
@@ -410,6 +607,34 @@ func TestDiffRecovery(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestSelectContainers(t *testing.T) {
+	c1 := newContainer("test", "1", config.ContainerName{Namespace: "test", Name: "2"})
+	c2 := newContainerWaitFor("test", "2", config.ContainerName{Namespace: "test", Name: "3"})
+	c3 := newContainer("test", "3", config.ContainerName{Namespace: "metrics", Name: "1"})
+	c4 := newContainer("test", "4")
+	c5 := newContainer("test", "5")
+	c5.Config.Links = config.Links{config.Link{ContainerName: config.ContainerName{Namespace: "test", Name: "4"}}}
+	m1 := newContainer("metrics", "1")
+
+	expected := []*Container{c1, c2, c3, c4, c5}
+	actual := []*Container{m1}
+
+	selected, err := SelectContainers("test", []string{"1"}, expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*Container{c1, c2, c3}, selected)
+
+	selected, err = SelectContainers("test", []string{"test.5", "3"}, expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*Container{c5, c3, c4}, selected)
+
+	_, err = SelectContainers("test", []string{"6"}, expected, actual)
+	assert.Error(t, err)
+}
+
 func newContainer(namespace string, name string, dependencies ...config.ContainerName) *Container {
 	return &Container{
 		State: &ContainerState{
@@ -436,6 +661,11 @@ func newContainerWaitFor(namespace string, name string, dependencies ...config.C
 
 func (m *clientMock) GetContainers(global bool) ([]*Container, error) {
 	args := m.Called()
+	// either Return(err) or Return(containers, err)
+	if len(args) > 1 {
+		containers, _ := args.Get(0).([]*Container)
+		return containers, args.Error(1)
+	}
 	return nil, args.Error(0)
 }
 
@@ -449,6 +679,107 @@ func (m *clientMock) RunContainer(container *Container) error {
 	return args.Error(0)
 }
 
+func (m *clientMock) UpdateContainer(container *Container) error {
+	args := m.Called(container)
+	return args.Error(0)
+}
+
+func (m *clientMock) RenameContainer(container *Container, name *config.ContainerName) error {
+	args := m.Called(container, name)
+	return args.Error(0)
+}
+
+func (m *clientMock) GetNetworks(ns string) ([]*Network, error) {
+	args := m.Called(ns)
+	networks, _ := args.Get(0).([]*Network)
+	return networks, args.Error(1)
+}
+
+func (m *clientMock) CreateNetwork(network *Network) error {
+	args := m.Called(network)
+	return args.Error(0)
+}
+
+func (m *clientMock) RemoveNetwork(network *Network) error {
+	args := m.Called(network)
+	return args.Error(0)
+}
+
+func (m *clientMock) RecreateNetwork(network, actual *Network) error {
+	args := m.Called(network, actual)
+	return args.Error(0)
+}
+
+func (m *clientMock) GetVolumes(ns string) ([]*Volume, error) {
+	args := m.Called(ns)
+	volumes, _ := args.Get(0).([]*Volume)
+	return volumes, args.Error(1)
+}
+
+func (m *clientMock) CreateVolume(volume *Volume) error {
+	args := m.Called(volume)
+	return args.Error(0)
+}
+
+func (m *clientMock) RemoveVolume(volume *Volume) error {
+	args := m.Called(volume)
+	return args.Error(0)
+}
+
+func (m *clientMock) BackupVolumes(container *Container, w io.Writer) error {
+	args := m.Called(container, w)
+	return args.Error(0)
+}
+
+func (m *clientMock) RestoreVolumes(container *Container, r io.Reader) error {
+	args := m.Called(container, r)
+	return args.Error(0)
+}
+
+func (m *clientMock) MigrateVolumes(from, to *Container) error {
+	args := m.Called(from, to)
+	return args.Error(0)
+}
+
+func (m *clientMock) WatchContainers(ns string, changes chan<- string, stop <-chan struct{}) error {
+	args := m.Called(ns)
+	return args.Error(0)
+}
+
+func (m *clientMock) ContainerLogs(container *Container, stdout, stderr io.Writer, opts LogsOptions) error {
+	args := m.Called(container, opts)
+	if data, ok := args.Get(0).(string); ok {
+		io.WriteString(stdout, data)
+		return args.Error(1)
+	}
+	return args.Error(0)
+}
+
+func (m *clientMock) StartContainer(container *Container) error {
+	args := m.Called(container)
+	return args.Error(0)
+}
+
+func (m *clientMock) StopContainer(container *Container) error {
+	args := m.Called(container)
+	return args.Error(0)
+}
+
+func (m *clientMock) PauseContainer(container *Container) error {
+	args := m.Called(container)
+	return args.Error(0)
+}
+
+func (m *clientMock) UnpauseContainer(container *Container) error {
+	args := m.Called(container)
+	return args.Error(0)
+}
+
+func (m *clientMock) ExecContainer(container *Container, opts ExecOptions) (int, error) {
+	args := m.Called(container, opts)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *clientMock) EnsureContainerExist(container *Container) error {
 	args := m.Called(container)
 	return args.Error(0)
@@ -134,7 +134,7 @@ func PullDockerImage(client *docker.Client, image *imagename.ImageName, auth *do
 			out = def.Writer()
 		}
 
-		if err := jsonmessage.DisplayJSONMessagesStream(pipeReader, out, fd, isTerminal); err != nil {
+		if err := jsonmessage.DisplayJSONMessagesStream(pipeReader, out, fd, isTerminal, nil); err != nil {
 			return nil, fmt.Errorf("Failed to process json stream for image: %s, error: %s", image, err)
 		}
 
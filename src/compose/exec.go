@@ -0,0 +1,110 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"io"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// ExecOptions are options of 'rocker-compose exec'
+type ExecOptions struct {
+	Cmd    []string
+	User   string
+	Tty    bool
+	Stdin  io.Reader // nil if stdin should not be attached
+	Stdout io.Writer
+	Stderr io.Writer
+	Height int // initial size of the TTY, if known
+	Width  int
+}
+
+// ExecContainer runs the command in the running container attaching the given streams
+// and returns its exit code once it finishes
+func (client *DockerClient) ExecContainer(container *Container, opts ExecOptions) (int, error) {
+	log.Debugf("Exec %q in container %s id:%.12s", opts.Cmd, container.Name, container.ID)
+
+	exec, err := client.Docker.CreateExec(docker.CreateExecOptions{
+		Container:    container.ID,
+		Cmd:          opts.Cmd,
+		User:         opts.User,
+		Tty:          opts.Tty,
+		AttachStdin:  opts.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create exec in container %s, error: %s", container.Name, err)
+	}
+
+	success := make(chan struct{})
+	errors := make(chan error, 1)
+
+	go func() {
+		errors <- client.Docker.StartExec(exec.ID, docker.StartExecOptions{
+			InputStream:  opts.Stdin,
+			OutputStream: opts.Stdout,
+			ErrorStream:  opts.Stderr,
+			Tty:          opts.Tty,
+			RawTerminal:  opts.Tty,
+			Success:      success,
+		})
+	}()
+
+	select {
+	case err := <-errors:
+		return 0, fmt.Errorf("Failed to start exec in container %s, error: %s", container.Name, err)
+	case ack := <-success:
+		if opts.Tty && opts.Height > 0 && opts.Width > 0 {
+			if err := client.Docker.ResizeExecTTY(exec.ID, opts.Height, opts.Width); err != nil {
+				log.Debugf("Failed to resize TTY of exec in container %s, error: %s", container.Name, err)
+			}
+		}
+		success <- ack
+	}
+
+	if err := <-errors; err != nil {
+		return 0, fmt.Errorf("Exec in container %s failed, error: %s", container.Name, err)
+	}
+
+	inspect, err := client.Docker.InspectExec(exec.ID)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to inspect exec in container %s, error: %s", container.Name, err)
+	}
+
+	return inspect.ExitCode, nil
+}
+
+// ExecAction implements 'rocker-compose exec'
+// It resolves the container name the same way as the manifest does, e.g. "web" is the
+// container of the manifest namespace and "other.web" is the one of another namespace.
+// Returns the exit code of the command.
+func (compose *Compose) ExecAction(name string, opts ExecOptions) (int, error) {
+	container, err := compose.findContainer(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if !container.State.Running {
+		return 0, fmt.Errorf("Container %s is not running", container.Name)
+	}
+
+	return compose.client.ExecContainer(container, opts)
+}
@@ -0,0 +1,55 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecAction(t *testing.T) {
+	web := newContainer("test", "web")
+	db := newContainer("test", "db")
+	db.State.Running = false
+	other := newContainer("other", "web")
+
+	opts := ExecOptions{Cmd: []string{"ls", "-la"}}
+
+	client := clientMock{}
+	client.On("GetContainers").Return([]*Container{web, db, other}, nil)
+	client.On("ExecContainer", web, opts).Return(3, nil)
+	client.On("ExecContainer", other, opts).Return(0, nil)
+
+	compose := &Compose{client: &client, Manifest: &config.Config{Namespace: "test"}}
+
+	code, err := compose.ExecAction("web", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, code)
+
+	// containers of other namespaces are referred by the full name
+	code, err = compose.ExecAction("other.web", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, code)
+
+	_, err = compose.ExecAction("db", opts)
+	assert.EqualError(t, err, "Container test.db is not running")
+
+	_, err = compose.ExecAction("cache", opts)
+	assert.EqualError(t, err, "Container test.cache does not exist")
+}
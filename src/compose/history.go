@@ -0,0 +1,288 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+)
+
+// History keeps revisions of manifests applied to namespaces in a local
+// state directory. Every docker host and namespace on it has its own subdirectory
+// with a JSON file per revision, e.g. <dir>/tcp_10.0.0.1_2376/myapp/3.json, so
+// the same namespace on different hosts has separate revisions. Only the owner
+// can read them, though secrets are kept as hashes anyway.
+type History struct {
+	Dir  string
+	Host string
+}
+
+// Revision is a single entry of the History: the rendered specs of all containers
+// of the namespace and the images they were running at the moment. Specs keep
+// salted hashes of secrets, the same way as container labels do.
+type Revision struct {
+	Number      int                           `json:"revision"`
+	Namespace   string                        `json:"namespace"`
+	Timestamp   time.Time                     `json:"timestamp"`
+	Description string                        `json:"description,omitempty"`
+	Containers  map[string]*RevisionContainer `json:"containers"`
+	Networks    map[string]*config.Network    `json:"networks,omitempty"`
+	Volumes     map[string]*config.Volume     `json:"volumes,omitempty"`
+}
+
+// RevisionContainer is a container of the Revision
+type RevisionContainer struct {
+	Image   string            `json:"image,omitempty"`
+	ImageID string            `json:"image_id,omitempty"`
+	Spec    *config.Container `json:"spec"`
+}
+
+// NewHistory makes a History object which keeps revisions of namespaces
+// of the docker host, given by its endpoint, in the given directory
+func NewHistory(dir, host string) *History {
+	return &History{Dir: dir, Host: host}
+}
+
+// NewRevision makes a revision of the namespace out of the given containers,
+// which should have images resolved. The revision is not numbered until saved.
+func NewRevision(ns string, containers []*Container) *Revision {
+	rev := &Revision{
+		Namespace:  ns,
+		Timestamp:  time.Now(),
+		Containers: map[string]*RevisionContainer{},
+	}
+	for _, container := range containers {
+		rev.Containers[container.Name.Name] = &RevisionContainer{
+			Image:   planImageName(container),
+			ImageID: container.ImageID,
+			Spec:    container.Config.HashSecrets(),
+		}
+	}
+	return rev
+}
+
+// List returns all revisions of the namespace in ascending order
+func (h *History) List(ns string) ([]*Revision, error) {
+	files, err := ioutil.ReadDir(h.namespaceDir(ns))
+	if os.IsNotExist(err) {
+		return []*Revision{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read history of namespace %s, error: %s", ns, err)
+	}
+
+	numbers := []int{}
+	for _, file := range files {
+		name := file.Name()
+		if filepath.Ext(name) != ".json" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	revisions := []*Revision{}
+	for _, n := range numbers {
+		rev, err := h.Get(ns, n)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, nil
+}
+
+// Get reads the revision of the namespace by number
+func (h *History) Get(ns string, number int) (*Revision, error) {
+	data, err := ioutil.ReadFile(h.revisionFile(ns, number))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Revision %d of namespace %s does not exist", number, ns)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read revision %d of namespace %s, error: %s", number, ns, err)
+	}
+
+	rev := &Revision{}
+	if err := json.Unmarshal(data, rev); err != nil {
+		return nil, fmt.Errorf("Failed to parse revision %d of namespace %s, error: %s", number, ns, err)
+	}
+
+	return rev, nil
+}
+
+// Last returns the latest revision of the namespace or nil if there is none
+func (h *History) Last(ns string) (*Revision, error) {
+	revisions, err := h.List(ns)
+	if err != nil || len(revisions) == 0 {
+		return nil, err
+	}
+	return revisions[len(revisions)-1], nil
+}
+
+// Save numbers the revision as the next one of its namespace and writes it
+func (h *History) Save(rev *Revision) error {
+	last, err := h.Last(rev.Namespace)
+	if err != nil {
+		return err
+	}
+
+	rev.Number = 1
+	if last != nil {
+		rev.Number = last.Number + 1
+	}
+
+	data, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to serialize revision, error: %s", err)
+	}
+
+	if err := os.MkdirAll(h.namespaceDir(rev.Namespace), 0700); err != nil {
+		return fmt.Errorf("Failed to create history directory, error: %s", err)
+	}
+
+	if err := ioutil.WriteFile(h.revisionFile(rev.Namespace, rev.Number), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("Failed to write revision %d of namespace %s, error: %s", rev.Number, rev.Namespace, err)
+	}
+
+	return nil
+}
+
+// Merge adds containers of the previous revision that are missing in this one.
+// It is used when only some of the containers were run.
+func (r *Revision) Merge(previous *Revision) {
+	for name, container := range previous.Containers {
+		if _, ok := r.Containers[name]; !ok {
+			r.Containers[name] = container
+		}
+	}
+}
+
+// Matches returns true if the revision has the same specs and images of the given containers,
+// and networks and volumes of the manifest, so there is nothing new to record. If partial
+// is true, other containers of the revision are not compared.
+func (r *Revision) Matches(manifest *config.Config, containers []*Container, partial bool) bool {
+	if !partial && len(r.Containers) != len(containers) {
+		return false
+	}
+	for _, container := range containers {
+		rc, ok := r.Containers[container.Name.Name]
+		if !ok || rc.Image != planImageName(container) || rc.ImageID != container.ImageID {
+			return false
+		}
+		// secrets of the revision are hashed, they are compared by hashes
+		if !container.Config.IsEqualTo(rc.Spec) {
+			return false
+		}
+	}
+	return sameMaps(r.Networks, manifest.Networks) && sameMaps(r.Volumes, manifest.Volumes)
+}
+
+// Config makes the manifest out of the revision, which can be run as usual.
+// Images of containers are pinned to the tags resolved at the moment of the revision,
+// see ImageIDs for the images themselves. Values of secrets are taken from the current
+// manifest, since the revision keeps only their hashes; fails if some of them are not
+// in the manifest or have changed since the revision.
+func (r *Revision) Config(current *config.Config) (*config.Config, error) {
+	cfg := &config.Config{
+		Namespace:  r.Namespace,
+		Containers: map[string]*config.Container{},
+		Networks:   r.Networks,
+		Volumes:    r.Volumes,
+	}
+	for name, container := range r.Containers {
+		spec := container.Spec
+		if spec.HasHashedSecrets() {
+			resolved, err := spec.ResolveSecrets(manifestSpec(current, config.NewContainerName(r.Namespace, name)))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to take secrets of container %s from the manifest, error: %s", name, err)
+			}
+			spec = resolved
+		}
+		pinned := *spec
+		if container.Image != "" {
+			image := container.Image
+			pinned.Image = &image
+		}
+		cfg.Containers[name] = &pinned
+	}
+	return cfg, nil
+}
+
+// ImageIDs returns ids of images that containers of the revision were running,
+// keyed by container name
+func (r *Revision) ImageIDs() map[string]string {
+	ids := map[string]string{}
+	for name, container := range r.Containers {
+		if container.ImageID != "" {
+			ids[name] = container.ImageID
+		}
+	}
+	return ids
+}
+
+// Images returns the list of container images of the revision, for printing
+func (r *Revision) Images() []string {
+	names := []string{}
+	for name := range r.Containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	images := []string{}
+	for _, name := range names {
+		images = append(images, fmt.Sprintf("%s=%s", name, r.Containers[name].Image))
+	}
+	return images
+}
+
+// sameMaps compares networks or volumes of revisions, treating nil and empty as the same,
+// since empty ones are not saved
+func sameMaps(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Len() == 0 && vb.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// hostDirChars are characters of the docker endpoint replaced in the name of its directory
+var hostDirChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func (h *History) namespaceDir(ns string) string {
+	host := strings.Trim(hostDirChars.ReplaceAllString(h.Host, "_"), "_")
+	return filepath.Join(h.Dir, host, ns)
+}
+
+func (h *History) revisionFile(ns string, number int) string {
+	return filepath.Join(h.namespaceDir(ns), fmt.Sprintf("%d.json", number))
+}
@@ -0,0 +1,186 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker/src/imagename"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	history := NewHistory(dir, "unix:///var/run/docker.sock")
+
+	revisions, err := history.List("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, revisions)
+
+	c1 := newContainer("test", "1")
+	c1.Image = imagename.NewFromString("quay.io/app:1.0")
+	c1.ImageID = "sha256:aaa"
+	c1.Config.Env = config.StringMap{"FOO": "bar"}
+	c2 := newContainer("test", "2")
+	c2.Image = imagename.NewFromString("quay.io/worker:1.0")
+
+	if err := history.Save(NewRevision("test", []*Container{c1, c2})); err != nil {
+		t.Fatal(err)
+	}
+
+	// only test.1 was run with a new version
+	c1.Image = imagename.NewFromString("quay.io/app:1.1")
+	rev := NewRevision("test", []*Container{c1})
+	last, err := history.Last("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev.Merge(last)
+	if err := history.Save(rev); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err = history.List("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, 1, revisions[0].Number)
+	assert.Equal(t, 2, revisions[1].Number)
+	assert.Equal(t, []string{"1=quay.io/app:1.0", "2=quay.io/worker:1.0"}, revisions[0].Images())
+	assert.Equal(t, []string{"1=quay.io/app:1.1", "2=quay.io/worker:1.0"}, revisions[1].Images())
+
+	// the manifest of the revision is pinned to resolved images
+	cfg, err := revisions[0].Config(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test", cfg.Namespace)
+	assert.Equal(t, "quay.io/app:1.0", *cfg.Containers["1"].Image)
+	assert.Equal(t, config.StringMap{"FOO": "bar"}, cfg.Containers["1"].Env)
+	assert.Equal(t, map[string]string{"1": "sha256:aaa"}, revisions[0].ImageIDs())
+
+	// only the owner can read the history
+	info, err := os.Stat(history.revisionFile("test", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = history.Get("test", 3)
+	assert.Error(t, err)
+
+	// namespaces of other docker hosts have their own history
+	revisions, err = NewHistory(dir, "tcp://10.0.0.1:2376").List("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, revisions)
+	assert.Equal(t, filepath.Join(dir, "unix_var_run_docker.sock", "test", "1.json"), history.revisionFile("test", 1))
+}
+
+func TestHistorySecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	history := NewHistory(dir, "unix:///var/run/docker.sock")
+
+	c1 := newContainer("test", "1")
+	c1.Config.Env = config.StringMap{"DB_PASSWORD": "qwerty"}
+	c1.Config.SecretEnv = config.Strings{"DB_PASSWORD"}
+	c1.Config.Secrets = config.Secrets{"db_key": &config.Secret{Value: "key"}}
+
+	if err := history.Save(NewRevision("test", []*Container{c1})); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(history.revisionFile("test", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, string(data), "qwerty")
+	assert.NotContains(t, string(data), `"key"`)
+
+	rev, err := history.Get("test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// values are taken from the current manifest
+	current := &config.Config{
+		Namespace:  "test",
+		Containers: map[string]*config.Container{"1": c1.Config},
+	}
+	cfg, err := rev.Config(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "qwerty", cfg.Containers["1"].Env["DB_PASSWORD"])
+	assert.Equal(t, "key", cfg.Containers["1"].Secrets["db_key"].Value)
+
+	// and cannot be restored if they have changed
+	current.Containers["1"] = &config.Container{
+		Env:     config.StringMap{"DB_PASSWORD": "123456"},
+		Secrets: c1.Config.Secrets,
+	}
+	_, err = rev.Config(current)
+	assert.Error(t, err)
+
+	_, err = rev.Config(nil)
+	assert.Error(t, err)
+}
+
+func TestHistoryMatches(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Image = imagename.NewFromString("quay.io/app:1.0")
+	c1.ImageID = "sha256:aaa"
+	c1.Config.Env = config.StringMap{"DB_PASSWORD": "qwerty"}
+	c1.Config.SecretEnv = config.Strings{"DB_PASSWORD"}
+	c2 := newContainer("test", "2")
+
+	manifest := &config.Config{Namespace: "test"}
+	rev := NewRevision("test", []*Container{c1, c2})
+
+	assert.True(t, rev.Matches(manifest, []*Container{c1, c2}, false))
+	assert.True(t, rev.Matches(manifest, []*Container{c1}, true))
+	assert.False(t, rev.Matches(manifest, []*Container{c1}, false))
+
+	c1.Config.Env = config.StringMap{"DB_PASSWORD": "123456"}
+	assert.False(t, rev.Matches(manifest, []*Container{c1, c2}, false))
+	c1.Config.Env = config.StringMap{"DB_PASSWORD": "qwerty"}
+
+	c1.ImageID = "sha256:bbb"
+	assert.False(t, rev.Matches(manifest, []*Container{c1, c2}, false))
+	c1.ImageID = "sha256:aaa"
+
+	manifest.Networks = map[string]*config.Network{"backend": {}}
+	assert.False(t, rev.Matches(manifest, []*Container{c1, c2}, false))
+}
@@ -0,0 +1,287 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker-compose/src/util"
+)
+
+// defaultKillTimeout is the number of seconds given to the container to stop
+// before killing it if kill_timeout is not specified, the same as docker does
+const defaultKillTimeout = 10
+
+// stateChange is a change of state of existing containers made by
+// 'rocker-compose start', 'stop', 'pause' and 'unpause' without recreating them
+type stateChange struct {
+	verb string
+	// dependent containers go first, e.g. when stopping
+	reverse bool
+	// returns true if the container needs the change
+	needed func(container *Container) bool
+	apply  func(client Client, container *Container) error
+}
+
+var (
+	startChange = &stateChange{
+		verb: "Start",
+		needed: func(c *Container) bool {
+			// containers of "created" and "ran" states are not supposed to run
+			return !c.State.Running && c.Config.State.Bool()
+		},
+		apply: func(client Client, c *Container) error {
+			return client.StartContainer(c)
+		},
+	}
+	stopChange = &stateChange{
+		verb:    "Stop",
+		reverse: true,
+		needed: func(c *Container) bool {
+			return c.State.Running
+		},
+		apply: func(client Client, c *Container) error {
+			return client.StopContainer(c)
+		},
+	}
+	pauseChange = &stateChange{
+		verb:    "Pause",
+		reverse: true,
+		needed: func(c *Container) bool {
+			return c.State.Running && !c.State.Paused
+		},
+		apply: func(client Client, c *Container) error {
+			return client.PauseContainer(c)
+		},
+	}
+	unpauseChange = &stateChange{
+		verb: "Unpause",
+		needed: func(c *Container) bool {
+			return c.State.Paused
+		},
+		apply: func(client Client, c *Container) error {
+			return client.UnpauseContainer(c)
+		},
+	}
+)
+
+// StopContainer stops the running container, giving it kill_timeout seconds
+// to exit before killing it
+func (client *DockerClient) StopContainer(container *Container) error {
+	timeout := uint(defaultKillTimeout)
+	if container.Config.KillTimeout != nil && *container.Config.KillTimeout > 0 {
+		timeout = *container.Config.KillTimeout
+	}
+
+	log.Infof("Stopping container %s id:%.12s", container.Name, container.ID)
+
+	if err := client.Docker.StopContainer(container.ID, timeout); err != nil {
+		if _, ok := err.(*docker.ContainerNotRunning); ok {
+			return nil
+		}
+		return fmt.Errorf("Failed to stop container %s, error: %s", container.Name, err)
+	}
+
+	return nil
+}
+
+// PauseContainer pauses all processes of the running container
+func (client *DockerClient) PauseContainer(container *Container) error {
+	log.Infof("Pausing container %s id:%.12s", container.Name, container.ID)
+
+	if err := client.Docker.PauseContainer(container.ID); err != nil {
+		return fmt.Errorf("Failed to pause container %s, error: %s", container.Name, err)
+	}
+
+	return nil
+}
+
+// UnpauseContainer resumes processes of the paused container
+func (client *DockerClient) UnpauseContainer(container *Container) error {
+	log.Infof("Unpausing container %s id:%.12s", container.Name, container.ID)
+
+	if err := client.Docker.UnpauseContainer(container.ID); err != nil {
+		return fmt.Errorf("Failed to unpause container %s, error: %s", container.Name, err)
+	}
+
+	return nil
+}
+
+// StartAction implements 'rocker-compose start'
+func (compose *Compose) StartAction() error {
+	return compose.changeState(startChange)
+}
+
+// StopAction implements 'rocker-compose stop'
+func (compose *Compose) StopAction() error {
+	return compose.changeState(stopChange)
+}
+
+// RestartAction implements 'rocker-compose restart'
+// Containers are stopped in the reverse order of dependencies, then started.
+func (compose *Compose) RestartAction() error {
+	if err := compose.changeState(stopChange); err != nil {
+		return err
+	}
+	return compose.changeState(startChange)
+}
+
+// PauseAction implements 'rocker-compose pause'
+func (compose *Compose) PauseAction() error {
+	return compose.changeState(pauseChange)
+}
+
+// UnpauseAction implements 'rocker-compose unpause'
+func (compose *Compose) UnpauseAction() error {
+	return compose.changeState(unpauseChange)
+}
+
+// changeState applies the change to existing containers of the manifest, or only to
+// those given with Only, by batches in the order of dependencies. Containers of the
+// same batch do not depend on each other, so they are changed in parallel.
+func (compose *Compose) changeState(change *stateChange) error {
+	batches, err := compose.dependencyBatches()
+	if err != nil {
+		return err
+	}
+
+	if change.reverse {
+		for i, j := 0, len(batches)-1; i < j; i, j = i+1, j-1 {
+			batches[i], batches[j] = batches[j], batches[i]
+		}
+	}
+
+	for _, batch := range batches {
+		containers := []*Container{}
+		for _, container := range batch {
+			if !change.needed(container) {
+				log.Debugf("Skip container %s, nothing to do", container.Name)
+				continue
+			}
+			if compose.DryRun {
+				log.Infof("[DRY] %s container %s", change.verb, container.Name)
+				continue
+			}
+			containers = append(containers, container)
+		}
+
+		wg := util.NewErrorWaitGroup(len(containers))
+		for _, container := range containers {
+			go func(container *Container) {
+				wg.Done(change.apply(compose.client, container))
+			}(container)
+		}
+		if err := wg.Wait(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dependencyBatches returns existing containers of the manifest (or only those
+// given with Only) split into batches: every container goes after all its dependencies
+// of the namespace (volumes_from, links, net and wait_for).
+func (compose *Compose) dependencyBatches() ([][]*Container, error) {
+	ns := compose.Manifest.Namespace
+
+	actual, err := compose.client.GetContainers(compose.Manifest.HasExternalRefs())
+	if err != nil {
+		return nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	expected := GetContainersFromConfig(compose.Manifest)
+
+	g := &graph{
+		ns:           ns,
+		dependencies: make(map[*Container][]*dependency),
+	}
+	if err := g.buildDependencyGraph(expected, actual); err != nil {
+		return nil, err
+	}
+	if g.hasCycles() {
+		return nil, fmt.Errorf("Dependencies have cycles, check links and volumes-from")
+	}
+
+	selected := expected
+	if len(compose.Only) > 0 {
+		selected = []*Container{}
+		for _, name := range compose.Only {
+			containerName := config.NewContainerNameFromString(name)
+			containerName.DefaultNamespace(ns)
+
+			container := find(expected, containerName)
+			if container == nil {
+				return nil, fmt.Errorf("Cannot find container %s in the manifest", containerName)
+			}
+			selected = append(selected, container)
+		}
+	}
+
+	batches := [][]*Container{}
+	for _, container := range selected {
+		existing := find(actual, container.Name)
+		if existing == nil {
+			return nil, fmt.Errorf("Container %s does not exist, use run to create it", container.Name)
+		}
+		if existing.Config == nil {
+			log.Warnf("Skip container %s, it was not created by rocker-compose", container.Name)
+			continue
+		}
+
+		level := g.dependencyLevel(container, map[*Container]int{})
+		for len(batches) <= level {
+			batches = append(batches, []*Container{})
+		}
+		batches[level] = append(batches[level], existing)
+	}
+
+	result := [][]*Container{}
+	for _, batch := range batches {
+		if len(batch) > 0 {
+			sort.Sort(containersByName(batch))
+			result = append(result, batch)
+		}
+	}
+
+	return result, nil
+}
+
+// dependencyLevel returns the length of the longest chain of dependencies
+// of the container within the namespace; the graph should have no cycles
+func (g *graph) dependencyLevel(container *Container, levels map[*Container]int) int {
+	if level, ok := levels[container]; ok {
+		return level
+	}
+
+	level := 0
+	for _, dep := range g.dependencies[container] {
+		if dep.external {
+			continue
+		}
+		if l := g.dependencyLevel(dep.container, levels) + 1; l > level {
+			level = l
+		}
+	}
+
+	levels[container] = level
+	return level
+}
@@ -0,0 +1,126 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLifecycleTest() (*config.Config, []*Container) {
+	image := "quay.io/app:1.0"
+	created := config.State("created")
+	cfg := &config.Config{
+		Namespace: "test",
+		Containers: map[string]*config.Container{
+			"data": &config.Container{Image: &image, State: &created},
+			"db":   &config.Container{Image: &image, VolumesFrom: config.ContainerNames{{Namespace: "test", Name: "data"}}},
+			"web":  &config.Container{Image: &image, Links: config.Links{{ContainerName: config.ContainerName{Namespace: "test", Name: "db"}}}},
+		},
+	}
+
+	data := newContainer("test", "data")
+	data.State.Running = false
+	data.Config.State = &created
+	db := newContainer("test", "db")
+	web := newContainer("test", "web")
+
+	return cfg, []*Container{web, db, data}
+}
+
+func TestLifecycleStop(t *testing.T) {
+	cfg, actual := newLifecycleTest()
+	web, db := actual[0], actual[1]
+
+	client := clientMock{}
+	client.On("GetContainers").Return(actual, nil)
+	client.On("StopContainer", web).Return(nil)
+	client.On("StopContainer", db).Return(nil)
+
+	compose := &Compose{client: &client, Manifest: cfg}
+	assert.NoError(t, compose.StopAction())
+	client.AssertExpectations(t)
+
+	// dependent containers are stopped first, data container is not running
+	assert.Len(t, client.Calls, 3)
+	assert.Equal(t, web, client.Calls[1].Arguments.Get(0))
+	assert.Equal(t, db, client.Calls[2].Arguments.Get(0))
+}
+
+func TestLifecycleStart(t *testing.T) {
+	cfg, actual := newLifecycleTest()
+	web, db, data := actual[0], actual[1], actual[2]
+	web.State.Running = false
+	db.State.Running = false
+
+	client := clientMock{}
+	client.On("GetContainers").Return(actual, nil)
+	client.On("StartContainer", db).Return(nil)
+	client.On("StartContainer", web).Return(nil)
+
+	compose := &Compose{client: &client, Manifest: cfg}
+	assert.NoError(t, compose.StartAction())
+	client.AssertExpectations(t)
+	client.AssertNotCalled(t, "StartContainer", data)
+
+	// dependencies are started first
+	assert.Len(t, client.Calls, 3)
+	assert.Equal(t, db, client.Calls[1].Arguments.Get(0))
+	assert.Equal(t, web, client.Calls[2].Arguments.Get(0))
+}
+
+func TestLifecycleOnly(t *testing.T) {
+	cfg, actual := newLifecycleTest()
+	db := actual[1]
+	db.State.Paused = true
+
+	client := clientMock{}
+	client.On("GetContainers").Return(actual, nil)
+	client.On("UnpauseContainer", db).Return(nil)
+
+	compose := &Compose{client: &client, Manifest: cfg, Only: []string{"db"}}
+	assert.NoError(t, compose.UnpauseAction())
+	client.AssertExpectations(t)
+
+	compose.Only = []string{"cache"}
+	assert.Error(t, compose.UnpauseAction())
+
+	// missing containers are not created
+	client = clientMock{}
+	client.On("GetContainers").Return(actual[:2], nil)
+	compose = &Compose{client: &client, Manifest: cfg}
+	assert.EqualError(t, compose.PauseAction(), "Container test.data does not exist, use run to create it")
+}
+
+func TestLifecycleUnmanaged(t *testing.T) {
+	cfg, actual := newLifecycleTest()
+	web, db := actual[0], actual[1]
+
+	// e.g. created with plain `docker run --name test.db`
+	db.Config = nil
+
+	client := clientMock{}
+	client.On("GetContainers").Return(actual, nil)
+	client.On("StopContainer", web).Return(nil)
+
+	compose := &Compose{client: &client, Manifest: cfg}
+	assert.NoError(t, compose.StopAction())
+	client.AssertExpectations(t)
+	client.AssertNotCalled(t, "StopContainer", db)
+}
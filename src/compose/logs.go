@@ -0,0 +1,230 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+)
+
+// LogsOptions are options of 'rocker-compose logs'
+type LogsOptions struct {
+	Follow     bool
+	Since      time.Time
+	Tail       string // number of lines from the end of logs or "all"
+	Timestamps bool
+	JSON       bool // print JSON object per line instead of the log format
+}
+
+// ContainerLogs streams logs of the container to the given writers. If follow is true,
+// it keeps streaming until the container stops. Docker puts the timestamp in front
+// of every line if timestamps is true.
+func (client *DockerClient) ContainerLogs(container *Container, stdout, stderr io.Writer, opts LogsOptions) error {
+	logsOptions := docker.LogsOptions{
+		Container:    container.Name.String(),
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+		Stdout:       true,
+		Stderr:       true,
+		Follow:       opts.Follow,
+		Timestamps:   opts.Timestamps || opts.JSON,
+		Tail:         opts.Tail,
+	}
+	if !opts.Since.IsZero() {
+		logsOptions.Since = opts.Since.Unix()
+	}
+	if container.container != nil && container.container.Config != nil {
+		logsOptions.RawTerminal = container.container.Config.Tty
+	}
+
+	if err := client.Docker.Logs(logsOptions); err != nil {
+		return fmt.Errorf("Failed to read logs of container %s, error: %s", container.Name, err)
+	}
+
+	return nil
+}
+
+// LogsAction implements 'rocker-compose logs'
+// It interleaves logs of the given containers, or of all containers of the namespace,
+// line by line to the writer. Every line is formatted like output of attached containers.
+func (compose *Compose) LogsAction(names []string, opts LogsOptions, w io.Writer) error {
+	actual, err := compose.client.GetContainers(false)
+	if err != nil {
+		return fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	containers := []*Container{}
+	if len(names) == 0 {
+		for _, container := range actual {
+			if container.Name.Namespace == compose.Manifest.Namespace {
+				containers = append(containers, container)
+			}
+		}
+		sort.Sort(containersByName(containers))
+	}
+	for _, name := range names {
+		containerName := config.NewContainerNameFromString(name)
+		containerName.DefaultNamespace(compose.Manifest.Namespace)
+
+		container := find(actual, containerName)
+		if container == nil {
+			return fmt.Errorf("Container %s does not exist", containerName)
+		}
+		containers = append(containers, container)
+	}
+
+	if len(containers) == 0 {
+		return fmt.Errorf("No containers in namespace %s", compose.Manifest.Namespace)
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		errors = make(chan error, len(containers))
+	)
+
+	for _, container := range containers {
+		stdout := newLogWriter(w, &mu, container, log.InfoLevel, opts)
+		stderr := newLogWriter(w, &mu, container, log.ErrorLevel, opts)
+
+		wg.Add(1)
+		go func(container *Container) {
+			defer wg.Done()
+			if err := compose.client.ContainerLogs(container, stdout, stderr, opts); err != nil {
+				errors <- err
+			}
+			stdout.Flush()
+			stderr.Flush()
+		}(container)
+	}
+
+	wg.Wait()
+
+	select {
+	case err := <-errors:
+		return err
+	default:
+	}
+
+	return nil
+}
+
+// logWriter splits the stream into lines and writes each line formatted with
+// the container formatter, the same way as output of attached containers, to the shared
+// writer. Writes of all logWriters of the same output are serialized with the mutex,
+// so lines do not mix.
+type logWriter struct {
+	out       io.Writer
+	mu        *sync.Mutex
+	formatter log.Formatter
+	opts      LogsOptions
+	buf       bytes.Buffer
+}
+
+// newLogWriter makes the writer of the container stream, stdout lines are logged
+// with info level and stderr lines with error level, see NewContainerIo
+func newLogWriter(out io.Writer, mu *sync.Mutex, container *Container, level log.Level, opts LogsOptions) *logWriter {
+	delegate := log.StandardLogger().Formatter
+	if opts.JSON {
+		delegate = &log.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+	}
+	return &logWriter{
+		out:       out,
+		mu:        mu,
+		formatter: newContainerFormatter(container, level, delegate),
+		opts:      opts,
+	}
+}
+
+// Write buffers the data and writes out every complete line
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		if err := w.writeLine(strings.TrimRight(line, "\r\n")); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes out the last line if it does not end with a newline
+func (w *logWriter) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	line := w.buf.String()
+	w.buf.Reset()
+	return w.writeLine(line)
+}
+
+func (w *logWriter) writeLine(line string) error {
+	entry := log.NewEntry(log.StandardLogger())
+	entry.Time = time.Now()
+	entry.Message = line
+
+	// docker puts the timestamp in front of the line, see ContainerLogs
+	if w.opts.JSON {
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+				entry.Time, entry.Message = t, line[i+1:]
+			}
+		}
+	}
+
+	data, err := w.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err = w.out.Write(data)
+	return err
+}
+
+// ParseLogsSince parses the value of 'logs --since', which is either
+// a duration relative to now, e.g. 10m, or an RFC 3339 timestamp
+func ParseLogsSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid --since value %q, expected a duration, e.g. 10m, or RFC 3339 timestamp", value)
+	}
+	return t, nil
+}
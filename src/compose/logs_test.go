@@ -0,0 +1,104 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLogWriter(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		mu  sync.Mutex
+	)
+
+	defer func(formatter log.Formatter) {
+		log.StandardLogger().Formatter = formatter
+	}(log.StandardLogger().Formatter)
+	log.StandardLogger().Formatter = &log.TextFormatter{DisableColors: true, DisableTimestamp: true}
+
+	w := newLogWriter(&buf, &mu, newContainer("test", "db"), log.InfoLevel, LogsOptions{})
+	w.Write([]byte("first\nsec"))
+	w.Write([]byte("ond\r\nthird"))
+	assert.Equal(t, "level=info msg=first container=test.db \nlevel=info msg=second container=test.db \n", buf.String())
+	w.Flush()
+	assert.Equal(t, "level=info msg=first container=test.db \nlevel=info msg=second container=test.db \nlevel=info msg=third container=test.db \n", buf.String())
+
+	buf.Reset()
+	w = newLogWriter(&buf, &mu, newContainer("test", "db"), log.ErrorLevel, LogsOptions{JSON: true})
+	w.Write([]byte("2016-05-10T12:00:00.123456789Z oops\n"))
+	assert.Equal(t, `{"container":"test.db","level":"error","msg":"oops","time":"2016-05-10T12:00:00.123456789Z"}
+`, buf.String())
+
+	buf.Reset()
+	w.Write([]byte("no timestamp\n"))
+	assert.Contains(t, buf.String(), `{"container":"test.db","level":"error","msg":"no timestamp","time":`)
+}
+
+func TestLogsParseSince(t *testing.T) {
+	now := time.Date(2016, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	since, err := ParseLogsSince("10m", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-10*time.Minute), since)
+
+	since, err = ParseLogsSince("2016-05-09T00:00:00Z", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2016, 5, 9, 0, 0, 0, 0, time.UTC), since)
+
+	since, err = ParseLogsSince("", now)
+	assert.NoError(t, err)
+	assert.True(t, since.IsZero())
+
+	_, err = ParseLogsSince("yesterday", now)
+	assert.Error(t, err)
+}
+
+func TestLogsAction(t *testing.T) {
+	web := newContainer("test", "web")
+	db := newContainer("test", "db")
+	other := newContainer("other", "app")
+
+	client := clientMock{}
+	client.On("GetContainers").Return([]*Container{web, db, other}, nil)
+	client.On("ContainerLogs", db, mock.Anything).Return("ready\n", nil)
+	client.On("ContainerLogs", web, mock.Anything).Return("started\n", nil)
+
+	compose := &Compose{client: &client, Manifest: &config.Config{Namespace: "test"}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, compose.LogsAction(nil, LogsOptions{JSON: true}, &buf))
+	client.AssertExpectations(t)
+	assert.Contains(t, buf.String(), `{"container":"test.db","level":"info","msg":"ready",`)
+	assert.Contains(t, buf.String(), `{"container":"test.web","level":"info","msg":"started",`)
+
+	buf.Reset()
+	assert.NoError(t, compose.LogsAction([]string{"web"}, LogsOptions{JSON: true}, &buf))
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"))
+	assert.Contains(t, buf.String(), `{"container":"test.web","level":"info","msg":"started",`)
+
+	assert.Error(t, compose.LogsAction([]string{"cache"}, LogsOptions{}, &buf))
+}
@@ -0,0 +1,173 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker-compose/src/util"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/go-yaml/yaml"
+)
+
+// Network is a user-defined docker network managed by rocker-compose.
+// Networks are named the same way as containers, i.e. namespace.name
+type Network struct {
+	ID         string
+	Name       *config.ContainerName
+	Config     *config.Network
+	Containers []string // ids of connected containers
+}
+
+// GetNetworksFromConfig returns the list of Network objects from
+// a spec Config object, sorted by name
+func GetNetworksFromConfig(cfg *config.Config) []*Network {
+	networks := []*Network{}
+	for name, networkConfig := range cfg.Networks {
+		if networkConfig == nil {
+			networkConfig = &config.Network{}
+		}
+		networks = append(networks, &Network{
+			Name:   config.NewContainerName(cfg.Namespace, name),
+			Config: networkConfig,
+		})
+	}
+	sort.Sort(networksByName(networks))
+	return networks
+}
+
+// NewNetworkFromDocker converts a network object given by docker client
+// to a local Network object. The spec is read from the label
+// that rocker-compose assigns on creation.
+func NewNetworkFromDocker(dockerNetwork *docker.Network) (*Network, error) {
+	network := &Network{
+		ID:         dockerNetwork.ID,
+		Name:       config.NewContainerNameFromString(dockerNetwork.Name),
+		Config:     &config.Network{},
+		Containers: []string{},
+	}
+	if err := yaml.Unmarshal([]byte(dockerNetwork.Labels["rocker-compose-config"]), network.Config); err != nil {
+		return nil, fmt.Errorf("Failed to parse spec of network %s, error: %s", dockerNetwork.Name, err)
+	}
+	for id := range dockerNetwork.Containers {
+		network.Containers = append(network.Containers, id)
+	}
+	sort.Strings(network.Containers)
+	return network, nil
+}
+
+// String returns network name
+func (n Network) String() string {
+	return n.Name.String()
+}
+
+// IsEqualTo returns true if both networks have the same spec
+func (n *Network) IsEqualTo(b *Network) bool {
+	specA, errA := yaml.Marshal(n.Config)
+	specB, errB := yaml.Marshal(b.Config)
+	return errA == nil && errB == nil && string(specA) == string(specB)
+}
+
+// CreateNetworkOptions returns create configuration eatable by go-dockerclient
+func (n *Network) CreateNetworkOptions() (*docker.CreateNetworkOptions, error) {
+	yamlData, err := yaml.Marshal(n.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	for k, v := range n.Config.Labels {
+		labels[k] = v
+	}
+	labels["rocker-compose-id"] = util.GenerateRandomID()
+	labels["rocker-compose-config"] = string(yamlData)
+
+	opts := &docker.CreateNetworkOptions{
+		Name:           n.Name.String(),
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Options:        map[string]interface{}{},
+		Labels:         labels,
+	}
+	if n.Config.Driver != nil {
+		opts.Driver = *n.Config.Driver
+	}
+	for k, v := range n.Config.DriverOpts {
+		opts.Options[k] = v
+	}
+	if n.Config.Internal != nil {
+		opts.Internal = *n.Config.Internal
+	}
+	if n.Config.Subnet != nil || n.Config.Gateway != nil {
+		ipam := docker.IPAMConfig{}
+		if n.Config.Subnet != nil {
+			ipam.Subnet = *n.Config.Subnet
+		}
+		if n.Config.Gateway != nil {
+			ipam.Gateway = *n.Config.Gateway
+		}
+		opts.IPAM = &docker.IPAMOptions{Config: []docker.IPAMConfig{ipam}}
+	}
+
+	return opts, nil
+}
+
+// diffNetworks compares the networks of the manifest with existing ones and returns
+// those to be created, recreated because their spec has changed, and removed
+func diffNetworks(expected, actual []*Network) (create, recreate, remove []*Network) {
+	for _, network := range expected {
+		existing := findNetwork(actual, network.Name)
+		if existing == nil {
+			create = append(create, network)
+		} else if !network.IsEqualTo(existing) {
+			recreate = append(recreate, network)
+		}
+	}
+	for _, network := range actual {
+		if findNetwork(expected, network.Name) == nil {
+			remove = append(remove, network)
+		}
+	}
+	return create, recreate, remove
+}
+
+func findNetwork(networks []*Network, name *config.ContainerName) *Network {
+	for _, network := range networks {
+		if network.Name.IsEqualTo(name) {
+			return network
+		}
+	}
+	return nil
+}
+
+// networksByName implements sort.Interface to sort networks by name
+type networksByName []*Network
+
+func (n networksByName) Len() int {
+	return len(n)
+}
+
+func (n networksByName) Less(i, j int) bool {
+	return n[i].Name.String() < n[j].Name.String()
+}
+
+func (n networksByName) Swap(i, j int) {
+	n[i], n[j] = n[j], n[i]
+}
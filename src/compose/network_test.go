@@ -0,0 +1,157 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkDiff(t *testing.T) {
+	subnet1, subnet2 := "172.28.0.0/16", "172.29.0.0/16"
+
+	cfg := &config.Config{
+		Namespace: "test",
+		Networks: map[string]*config.Network{
+			"backend":  &config.Network{Subnet: &subnet2},
+			"frontend": nil,
+			"new":      &config.Network{},
+		},
+	}
+	expected := GetNetworksFromConfig(cfg)
+
+	actual := []*Network{
+		&Network{ID: "n1", Name: config.NewContainerName("test", "backend"), Config: &config.Network{Subnet: &subnet1}},
+		&Network{ID: "n2", Name: config.NewContainerName("test", "frontend"), Config: &config.Network{}},
+		&Network{ID: "n3", Name: config.NewContainerName("test", "old"), Config: &config.Network{}},
+	}
+
+	create, recreate, remove := diffNetworks(expected, actual)
+
+	assert.Len(t, create, 1)
+	assert.Equal(t, "test.new", create[0].Name.String())
+	assert.Len(t, recreate, 1)
+	assert.Equal(t, "test.backend", recreate[0].Name.String())
+	assert.Len(t, remove, 1)
+	assert.Equal(t, "n3", remove[0].ID)
+}
+
+func TestNetworkCreateOptions(t *testing.T) {
+	driver, subnet := "overlay", "172.28.0.0/16"
+	network := &Network{
+		Name: config.NewContainerName("test", "backend"),
+		Config: &config.Network{
+			Driver:     &driver,
+			DriverOpts: config.StringMap{"encrypted": "true"},
+			Subnet:     &subnet,
+		},
+	}
+
+	opts, err := network.CreateNetworkOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "test.backend", opts.Name)
+	assert.Equal(t, "overlay", opts.Driver)
+	assert.Equal(t, "true", opts.Options["encrypted"])
+	assert.Equal(t, "172.28.0.0/16", opts.IPAM.Config[0].Subnet)
+	assert.Contains(t, opts.Labels["rocker-compose-config"], "subnet: 172.28.0.0/16")
+	assert.NotEmpty(t, opts.Labels["rocker-compose-id"])
+}
+
+func TestNetworkEnsure(t *testing.T) {
+	cfg := &config.Config{
+		Namespace: "test",
+		Networks: map[string]*config.Network{
+			"backend": &config.Network{},
+		},
+	}
+	old := &Network{ID: "n1", Name: config.NewContainerName("test", "old"), Config: &config.Network{}}
+
+	client := clientMock{}
+	client.On("GetNetworks", "test").Return([]*Network{old}, nil)
+	client.On("CreateNetwork", GetNetworksFromConfig(cfg)[0]).Return(nil)
+	client.On("RemoveNetwork", old).Return(nil)
+
+	compose := &Compose{client: &client, Manifest: cfg}
+
+	obsolete, err := compose.ensureNetworks(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*Network{old}, obsolete)
+
+	compose.removeNetworks(obsolete)
+	client.AssertExpectations(t)
+
+	// networks are kept on partial runs
+	compose.Only = []string{"app"}
+	obsolete, err = compose.ensureNetworks(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, obsolete)
+}
+
+func TestNetworkRecreate(t *testing.T) {
+	// the list of networks does not tell connected containers, only inspect does
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /networks/n1":
+			w.Write([]byte(`{"Id": "n1", "Name": "test.backend", "Containers": {"c1": {"Name": "test.app"}}}`))
+		case "POST /networks/create":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id": "n2"}`))
+		case "GET /containers/c1/json":
+			http.NotFound(w, r)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	dockerCli, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &DockerClient{Docker: dockerCli}
+
+	name := config.NewContainerName("test", "backend")
+	actual := &Network{ID: "n1", Name: name, Config: &config.Network{}, Containers: []string{}}
+	network := &Network{Name: name, Config: &config.Network{}}
+
+	if err := client.RecreateNetwork(network, actual); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{
+		"GET /networks/n1",
+		"GET /containers/c1/json",
+		"POST /networks/n1/disconnect",
+		"DELETE /networks/n1",
+		"POST /networks/create",
+		"POST /networks/n2/connect",
+	}, requests)
+}
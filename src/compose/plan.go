@@ -0,0 +1,343 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+)
+
+// ChangeType is a kind of change that is going to be made with a container
+type ChangeType string
+
+const (
+	// ChangeCreate means the container does not exist and will be created
+	ChangeCreate ChangeType = "create"
+	// ChangeUpdate means the existing container will be changed in place
+	ChangeUpdate ChangeType = "update"
+	// ChangeRecreate means the existing container will be removed and created again
+	ChangeRecreate ChangeType = "recreate"
+	// ChangeRemove means the existing container will be removed
+	ChangeRemove ChangeType = "remove"
+)
+
+// Plan is the list of container changes that rocker-compose is about to make.
+// Besides changes, it holds the execution plan and the snapshot of existing
+// containers it was computed against, so it can be saved and applied later.
+type Plan struct {
+	Namespace string
+	Changes   []*Change
+	Only      []string                   // containers the plan was restricted to, if any
+	Networks  map[string]*config.Network // networks of the manifest, ensured before containers
+	Volumes   map[string]*config.Volume  // volumes of the manifest, ensured before containers
+
+	actions  []Action
+	expected []*Container
+	actual   []*Container
+}
+
+// Change describes a single container change of the execution plan.
+// For updated and recreated containers Diff holds every property that differs
+// between the existing container and the spec.
+type Change struct {
+	Type      ChangeType
+	Container *Container
+	Diff      []config.FieldDiff
+}
+
+// NewPlan walks through the execution plan given by Diff and collects
+// the list of container changes sorted by container name. Only existing containers
+// of the namespace and those referenced by the execution plan are kept in the snapshot.
+func NewPlan(ns string, actions []Action, expected, actual []*Container) *Plan {
+	var (
+		changes = []*Change{}
+		removed = map[string]*Container{}
+		created = []*Container{}
+	)
+
+	WalkActions(actions, func(action Action) {
+		switch a := action.(type) {
+		case *removeContainer:
+			removed[a.container.Name.String()] = a.container
+		case *runContainer:
+			created = append(created, a.container)
+		case *replaceContainer:
+			changes = append(changes, &Change{
+				Type:      ChangeRecreate,
+				Container: a.container,
+				Diff:      a.container.Differences(a.actual),
+			})
+		case *migrateContainer:
+			changes = append(changes, &Change{
+				Type:      ChangeRecreate,
+				Container: a.container,
+				Diff:      a.container.Differences(a.actual),
+			})
+		case *updateContainer:
+			changes = append(changes, &Change{
+				Type:      ChangeUpdate,
+				Container: a.container,
+				Diff:      a.container.Differences(a.actual),
+			})
+		}
+	})
+
+	for _, container := range created {
+		name := container.Name.String()
+		actual, ok := removed[name]
+		if !ok {
+			changes = append(changes, &Change{Type: ChangeCreate, Container: container})
+			continue
+		}
+		delete(removed, name)
+		changes = append(changes, &Change{
+			Type:      ChangeRecreate,
+			Container: container,
+			Diff:      container.Differences(actual),
+		})
+	}
+
+	for _, container := range removed {
+		changes = append(changes, &Change{Type: ChangeRemove, Container: container})
+	}
+
+	sort.Sort(changesByName(changes))
+
+	referenced := map[*Container]struct{}{}
+	WalkActions(actions, func(action Action) {
+		if c := actionContainer(action); c != nil {
+			referenced[c] = struct{}{}
+		}
+	})
+
+	snapshot := []*Container{}
+	for _, container := range actual {
+		if _, ok := referenced[container]; ok || container.Name.Namespace == ns {
+			snapshot = append(snapshot, container)
+		}
+	}
+
+	return &Plan{
+		Namespace: ns,
+		Changes:   changes,
+		actions:   actions,
+		expected:  expected,
+		actual:    snapshot,
+	}
+}
+
+// IsEmpty returns true if the plan has no changes
+func (p *Plan) IsEmpty() bool {
+	return len(p.Changes) == 0
+}
+
+// Count returns the number of changes of the given type
+func (p *Plan) Count(t ChangeType) (n int) {
+	for _, change := range p.Changes {
+		if change.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+// HasExternalRefs returns true if the plan refers to containers of other namespaces
+func (p *Plan) HasExternalRefs() bool {
+	for _, container := range p.actual {
+		if container.Name.Namespace != p.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// manifest returns the manifest of the namespace with networks and volumes the plan was made with
+func (p *Plan) manifest() *config.Config {
+	return &config.Config{
+		Namespace: p.Namespace,
+		Networks:  p.Networks,
+		Volumes:   p.Volumes,
+	}
+}
+
+// HasHashedSecrets returns true if values of some secrets of the planned
+// containers are not saved in the plan, only their hashes are
+func (p *Plan) HasHashedSecrets() bool {
+	for _, container := range p.expected {
+		if container.Config.HasHashedSecrets() {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSecrets takes values of secrets, which are saved in the plan only as hashes,
+// from the manifest the plan was made from. Fails if some value is not in the manifest
+// or differs from the one the plan was made with.
+func (p *Plan) ResolveSecrets(manifest *config.Config) error {
+	for _, container := range p.expected {
+		if !container.Config.HasHashedSecrets() {
+			continue
+		}
+		spec, err := container.Config.ResolveSecrets(manifestSpec(manifest, container.Name))
+		if err != nil {
+			return fmt.Errorf("container %s: %s", container.Name, err)
+		}
+		container.Config = spec
+	}
+	return nil
+}
+
+// Verify checks that the given list of existing containers is the same as the one
+// the plan was computed against. Every container from the snapshot should still exist
+// with the same id and state, and no new containers should appear in the namespace
+// (or among the planned containers, if the plan was restricted to some of them).
+// On success, the snapshot is refreshed with the given containers.
+func (p *Plan) Verify(actual []*Container) error {
+	for _, snapshot := range p.actual {
+		current := find(actual, snapshot.Name)
+		if current == nil {
+			return fmt.Errorf("container %s does not exist anymore", snapshot.Name)
+		}
+		if current.ID != snapshot.ID {
+			return fmt.Errorf("container %s was recreated (was %.12s became %.12s)", snapshot.Name, snapshot.ID, current.ID)
+		}
+		if !current.State.IsEqualState(snapshot.State) {
+			return fmt.Errorf("container %s state changed (was %s became %s)", snapshot.Name, snapshot.State, current.State)
+		}
+		if current.State.ExitCode != snapshot.State.ExitCode {
+			return fmt.Errorf("container %s exit code changed (was %d became %d)",
+				snapshot.Name, snapshot.State.ExitCode, current.State.ExitCode)
+		}
+	}
+
+	for _, current := range actual {
+		if current.Name.Namespace != p.Namespace || find(p.actual, current.Name) != nil {
+			continue
+		}
+		if len(p.Only) == 0 || find(p.expected, current.Name) != nil {
+			return fmt.Errorf("container %s appeared in the namespace", current.Name)
+		}
+	}
+
+	// actions refer to snapshot containers, so update them in place
+	for _, snapshot := range p.actual {
+		*snapshot = *find(actual, snapshot.Name)
+	}
+
+	return nil
+}
+
+// WriteTo writes the human readable representation of the plan to the writer
+func (p *Plan) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	if p.IsEmpty() {
+		buf.WriteString("No changes. Containers are up-to-date.\n")
+		return buf.WriteTo(w)
+	}
+
+	for _, change := range p.Changes {
+		fmt.Fprintf(&buf, "%s %s (%s)\n", change.Type.Sign(), change.Container.Name, change.Type)
+
+		if change.Type == ChangeRecreate && len(change.Diff) == 0 {
+			buf.WriteString("    (forced by recreation of a dependency)\n")
+		}
+
+		for _, d := range change.Diff {
+			buf.WriteString(formatFieldDiff(d))
+		}
+	}
+
+	fmt.Fprintf(&buf, "\nPlan: %d to create, %d to update, %d to recreate, %d to remove.\n",
+		p.Count(ChangeCreate), p.Count(ChangeUpdate), p.Count(ChangeRecreate), p.Count(ChangeRemove))
+
+	return buf.WriteTo(w)
+}
+
+// Sign returns a short symbol of the change type, for printing
+func (t ChangeType) Sign() string {
+	switch t {
+	case ChangeCreate:
+		return "+"
+	case ChangeRemove:
+		return "-"
+	}
+	return "~"
+}
+
+// formatFieldDiff formats a single property difference; scalar values are
+// printed on one line, maps and lists as indented YAML blocks
+func formatFieldDiff(d config.FieldDiff) string {
+	if isScalarYaml(d.Old) && isScalarYaml(d.New) {
+		return fmt.Sprintf("    %s: %s => %s\n", d.Field, d.Old, d.New)
+	}
+
+	indent := func(str string) string {
+		return "        " + strings.Replace(str, "\n", "\n        ", -1)
+	}
+
+	return fmt.Sprintf("    %s:\n      was:\n%s\n      becomes:\n%s\n", d.Field, indent(d.Old), indent(d.New))
+}
+
+// actionContainer returns the container that the given action deals with
+func actionContainer(action Action) *Container {
+	switch a := action.(type) {
+	case *runContainer:
+		return a.container
+	case *removeContainer:
+		return a.container
+	case *updateContainer:
+		return a.container
+	case *replaceContainer:
+		return a.container
+	case *migrateContainer:
+		return a.container
+	case *waitContainerAction:
+		return a.container
+	case *ensureContainerExist:
+		return a.container
+	case *ensureContainerState:
+		return a.container
+	}
+	return nil
+}
+
+// isScalarYaml returns true if the given YAML is neither a map nor a list
+func isScalarYaml(yml string) bool {
+	return !strings.Contains(yml, "\n") && !strings.HasPrefix(yml, "- ") && !strings.Contains(yml, ": ")
+}
+
+// changesByName implements sort.Interface to sort changes by container name
+type changesByName []*Change
+
+func (c changesByName) Len() int {
+	return len(c)
+}
+
+func (c changesByName) Less(i, j int) bool {
+	return c[i].Container.Name.String() < c[j].Container.Name.String()
+}
+
+func (c changesByName) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
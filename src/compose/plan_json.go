@@ -0,0 +1,359 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker/src/imagename"
+)
+
+// planVersion is the version of the saved plan format
+const planVersion = 1
+
+// Names of action types in the saved plan
+const (
+	planActionStep        = "step"
+	planActionRun         = "run"
+	planActionRemove      = "remove"
+	planActionUpdate      = "update"
+	planActionReplace     = "replace"
+	planActionMigrate     = "migrate"
+	planActionWait        = "wait"
+	planActionEnsureExist = "ensure_exist"
+	planActionEnsureState = "ensure_state"
+)
+
+// planDocument is the JSON representation of the Plan
+type planDocument struct {
+	Version    int                        `json:"version"`
+	Namespace  string                     `json:"namespace"`
+	Only       []string                   `json:"only,omitempty"`
+	Networks   map[string]*config.Network `json:"networks,omitempty"`
+	Volumes    map[string]*config.Volume  `json:"volumes,omitempty"`
+	Containers map[string]*planContainer  `json:"containers"`
+	Actual     map[string]*planContainer  `json:"actual"`
+	Changes    []*planChange              `json:"changes"`
+	Actions    []*planAction              `json:"actions"`
+}
+
+// planContainer is the JSON representation of either expected
+// container (with spec) or existing one (with id)
+type planContainer struct {
+	ID       string            `json:"id,omitempty"`
+	Image    string            `json:"image,omitempty"`
+	ImageID  string            `json:"image_id,omitempty"`
+	State    string            `json:"state"`
+	ExitCode int               `json:"exit_code,omitempty"`
+	Spec     *config.Container `json:"spec,omitempty"`
+}
+
+// planChange is the JSON representation of the Change
+type planChange struct {
+	Type      ChangeType         `json:"type"`
+	Container string             `json:"container"`
+	Diff      []config.FieldDiff `json:"diff,omitempty"`
+}
+
+// planAction is the JSON representation of the Action. Container
+// is a reference by name to one of containers of the document.
+type planAction struct {
+	Type      string        `json:"type"`
+	Container string        `json:"container,omitempty"`
+	Async     bool          `json:"async,omitempty"`
+	Actions   []*planAction `json:"actions,omitempty"`
+}
+
+// ReadPlan reads the plan previously saved by Plan.MarshalJSON
+func ReadPlan(reader io.Reader) (*Plan, error) {
+	doc := &planDocument{}
+	if err := json.NewDecoder(reader).Decode(doc); err != nil {
+		return nil, fmt.Errorf("Failed to parse plan, error: %s", err)
+	}
+	if doc.Version != planVersion {
+		return nil, fmt.Errorf("Unsupported plan version %d, expected %d", doc.Version, planVersion)
+	}
+
+	plan := &Plan{
+		Namespace: doc.Namespace,
+		Only:      doc.Only,
+		Networks:  doc.Networks,
+		Volumes:   doc.Volumes,
+		Changes:   []*Change{},
+		expected:  []*Container{},
+		actual:    []*Container{},
+	}
+
+	for name, c := range doc.Containers {
+		if c.Spec == nil {
+			return nil, fmt.Errorf("Missing spec of container %s in the plan", name)
+		}
+		container := NewContainerFromConfig(config.NewContainerNameFromString(name), c.Spec)
+		if c.Image != "" {
+			container.Image = imagename.NewFromString(c.Image)
+		}
+		container.ImageID = c.ImageID
+		plan.expected = append(plan.expected, container)
+	}
+
+	for name, c := range doc.Actual {
+		container := &Container{
+			ID:      c.ID,
+			Name:    config.NewContainerNameFromString(name),
+			ImageID: c.ImageID,
+			State: &ContainerState{
+				Running:  c.State == "running",
+				ExitCode: c.ExitCode,
+			},
+			Config: &config.Container{},
+		}
+		if c.Image != "" {
+			container.Image = imagename.NewFromString(c.Image)
+		}
+		plan.actual = append(plan.actual, container)
+	}
+
+	// expected containers take precedence except for removal, which deals with existing ones
+	resolve := func(actionType, name string) (*Container, error) {
+		containerName := config.NewContainerNameFromString(name)
+		if actionType != planActionRemove {
+			if container := find(plan.expected, containerName); container != nil {
+				return container, nil
+			}
+		}
+		if container := find(plan.actual, containerName); container != nil {
+			return container, nil
+		}
+		return nil, fmt.Errorf("Plan refers to unknown container %s", name)
+	}
+
+	for _, c := range doc.Changes {
+		actionType := planActionRun
+		if c.Type == ChangeRemove {
+			actionType = planActionRemove
+		}
+		container, err := resolve(actionType, c.Container)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, &Change{
+			Type:      c.Type,
+			Container: container,
+			Diff:      c.Diff,
+		})
+	}
+
+	actions, err := decodePlanActions(doc.Actions, resolve)
+	if err != nil {
+		return nil, err
+	}
+	plan.actions = actions
+
+	return plan, nil
+}
+
+// MarshalJSON serializes the plan to a stable JSON document, which
+// includes container specs, resolved images, networks, volumes and the execution plan.
+// Secrets are hashed the same way as in container labels, so their
+// values are taken from the manifest again when the plan is applied.
+func (p *Plan) MarshalJSON() ([]byte, error) {
+	doc := &planDocument{
+		Version:    planVersion,
+		Namespace:  p.Namespace,
+		Only:       p.Only,
+		Networks:   p.Networks,
+		Volumes:    p.Volumes,
+		Containers: map[string]*planContainer{},
+		Actual:     map[string]*planContainer{},
+		Changes:    []*planChange{},
+	}
+
+	for _, c := range p.expected {
+		doc.Containers[c.Name.String()] = &planContainer{
+			Image:   planImageName(c),
+			ImageID: c.ImageID,
+			State:   c.State.String(),
+			Spec:    c.Config.HashSecrets(),
+		}
+	}
+
+	for _, c := range p.actual {
+		doc.Actual[c.Name.String()] = &planContainer{
+			ID:       c.ID,
+			Image:    planImageName(c),
+			ImageID:  c.ImageID,
+			State:    c.State.String(),
+			ExitCode: c.State.ExitCode,
+		}
+	}
+
+	for _, c := range p.Changes {
+		doc.Changes = append(doc.Changes, &planChange{
+			Type:      c.Type,
+			Container: c.Container.Name.String(),
+			Diff:      c.Diff,
+		})
+	}
+
+	actions, err := encodePlanActions(p.actions)
+	if err != nil {
+		return nil, err
+	}
+	doc.Actions = actions
+
+	return json.Marshal(doc)
+}
+
+// encodePlanActions converts the action tree to its JSON representation.
+// Actions of async steps are sorted, since their order does not matter.
+func encodePlanActions(actions []Action) ([]*planAction, error) {
+	result := []*planAction{}
+
+	for _, action := range actions {
+		node := &planAction{}
+
+		switch a := action.(type) {
+		case *noAction:
+			continue
+		case *stepAction:
+			children, err := encodePlanActions(a.actions)
+			if err != nil {
+				return nil, err
+			}
+			if a.async {
+				sort.Sort(planActionsByString(children))
+			}
+			node.Type = planActionStep
+			node.Async = a.async
+			node.Actions = children
+		case *runContainer:
+			node.Type = planActionRun
+		case *removeContainer:
+			node.Type = planActionRemove
+		case *updateContainer:
+			node.Type = planActionUpdate
+		case *replaceContainer:
+			node.Type = planActionReplace
+		case *migrateContainer:
+			node.Type = planActionMigrate
+		case *waitContainerAction:
+			node.Type = planActionWait
+		case *ensureContainerExist:
+			node.Type = planActionEnsureExist
+		case *ensureContainerState:
+			node.Type = planActionEnsureState
+		default:
+			return nil, fmt.Errorf("Cannot save action to the plan: %s", action)
+		}
+
+		if c := actionContainer(action); c != nil {
+			node.Container = c.Name.String()
+		}
+
+		result = append(result, node)
+	}
+
+	return result, nil
+}
+
+// decodePlanActions converts JSON representation of actions back to the action tree
+func decodePlanActions(nodes []*planAction, resolve func(actionType, name string) (*Container, error)) ([]Action, error) {
+	result := []Action{}
+
+	for _, node := range nodes {
+		if node.Type == planActionStep {
+			children, err := decodePlanActions(node.Actions, resolve)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, &stepAction{actions: children, async: node.Async})
+			continue
+		}
+
+		container, err := resolve(node.Type, node.Container)
+		if err != nil {
+			return nil, err
+		}
+
+		switch node.Type {
+		case planActionRun:
+			result = append(result, NewRunContainerAction(container))
+		case planActionRemove:
+			result = append(result, NewRemoveContainerAction(container))
+		case planActionUpdate, planActionReplace, planActionMigrate:
+			// the existing container is resolved the same way as for removal
+			actual, err := resolve(planActionRemove, node.Container)
+			if err != nil {
+				return nil, err
+			}
+			switch node.Type {
+			case planActionUpdate:
+				result = append(result, NewUpdateContainerAction(container, actual))
+			case planActionReplace:
+				result = append(result, NewReplaceContainerAction(container, actual))
+			default:
+				result = append(result, NewMigrateContainerAction(container, actual))
+			}
+		case planActionWait:
+			result = append(result, NewWaitContainerAction(container))
+		case planActionEnsureExist:
+			result = append(result, NewEnsureContainerExistAction(container))
+		case planActionEnsureState:
+			result = append(result, NewEnsureContainerStateAction(container))
+		default:
+			return nil, fmt.Errorf("Unknown action type in the plan: %s", node.Type)
+		}
+	}
+
+	return result, nil
+}
+
+// planImageName returns the image name of the container or an empty string if there is no image
+func planImageName(c *Container) string {
+	if c.Image == nil {
+		return ""
+	}
+	return c.Image.String()
+}
+
+// planActionsByString implements sort.Interface to sort actions of async steps
+type planActionsByString []*planAction
+
+func (a planActionsByString) Len() int {
+	return len(a)
+}
+
+func (a planActionsByString) Less(i, j int) bool {
+	return a[i].String() < a[j].String()
+}
+
+func (a planActionsByString) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+// String returns the string representation of the action subtree, used for sorting
+func (a *planAction) String() string {
+	str := a.Type + ":" + a.Container
+	for _, child := range a.Actions {
+		str += "," + child.String()
+	}
+	return str
+}
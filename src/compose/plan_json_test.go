@@ -0,0 +1,232 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"testing"
+
+	"github.com/grammarly/rocker/src/imagename"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPlanJSON(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Image = imagename.NewFromString("quay.io/app:1.2")
+	c1.ImageID = "sha256:aaa"
+	c1.Config.Env = config.StringMap{"FOO": "bar"}
+
+	c1x := newContainer("test", "1")
+	c1x.ID = "c1x"
+	c1x.Image = imagename.NewFromString("quay.io/app:1.1")
+	c1x.ImageID = "sha256:bbb"
+
+	c2 := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "1"})
+	c2.Image = imagename.NewFromString("quay.io/worker:1.0")
+
+	c3x := newContainer("test", "3")
+	c3x.ID = "c3x"
+
+	m1 := newContainer("metrics", "1")
+	m1.ID = "m1"
+
+	expected := []*Container{c1, c2}
+	actual := []*Container{c1x, c3x, m1}
+
+	actions, err := NewDiff("test").Diff(expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPlan("test", actions, expected, actual)
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// containers of other namespaces are not in the snapshot unless referenced
+	assert.False(t, plan.HasExternalRefs())
+	assert.NotContains(t, string(data), "metrics.1")
+
+	// the document should be stable
+	data2, err := json.Marshal(NewPlan("test", actions, expected, actual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(data), string(data2))
+
+	restored, err := ReadPlan(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "test", restored.Namespace)
+	assert.Len(t, restored.Changes, 3)
+
+	var text1, text2 bytes.Buffer
+	plan.WriteTo(&text1)
+	restored.WriteTo(&text2)
+	assert.Equal(t, text1.String(), text2.String())
+
+	restoredC1 := find(restored.expected, c1.Name)
+	assert.Equal(t, "quay.io/app:1.2", restoredC1.Image.String())
+	assert.Equal(t, "sha256:aaa", restoredC1.ImageID)
+	assert.Equal(t, "bar", restoredC1.Config.Env["FOO"])
+
+	// the same actions should be executed
+	mock := clientMock{}
+	mock.On("RemoveContainer", find(restored.actual, c1x.Name)).Return(nil)
+	mock.On("RemoveContainer", find(restored.actual, c3x.Name)).Return(nil)
+	mock.On("RunContainer", restoredC1).Return(nil)
+	mock.On("RunContainer", find(restored.expected, c2.Name)).Return(nil)
+	NewDockerClientRunner(&mock).Run(restored.actions)
+	mock.AssertExpectations(t)
+}
+
+func TestPlanVerify(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1x := newContainer("test", "1")
+	c1x.ID = "c1x"
+	c1x.Config.Env = config.StringMap{"FOO": "bar"}
+
+	actions, err := NewDiff("test").Diff([]*Container{c1}, []*Container{c1x})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := NewPlan("test", actions, []*Container{c1}, []*Container{c1x})
+
+	// same state
+	current := *c1x
+	assert.Nil(t, plan.Verify([]*Container{&current}))
+
+	// recreated
+	recreated := *c1x
+	recreated.ID = "c1y"
+	assert.Error(t, plan.Verify([]*Container{&recreated}))
+
+	// stopped
+	stopped := *c1x
+	stopped.State = &ContainerState{Running: false}
+	assert.Error(t, plan.Verify([]*Container{&stopped}))
+
+	// removed
+	assert.Error(t, plan.Verify([]*Container{}))
+
+	// appeared
+	c2 := newContainer("test", "2")
+	assert.Error(t, plan.Verify([]*Container{&current, c2}))
+}
+
+func TestPlanJSONSecrets(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Env = config.StringMap{"DB_PASSWORD": "qwerty"}
+	c1.Config.SecretEnv = config.Strings{"DB_PASSWORD"}
+	c1.Config.Secrets = config.Secrets{"db_key": &config.Secret{Value: "key", Target: "/run/secrets/db_key"}}
+
+	actions, err := NewDiff("test").Diff([]*Container{c1}, []*Container{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(NewPlan("test", actions, []*Container{c1}, []*Container{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// values of secrets are not saved
+	assert.NotContains(t, string(data), "qwerty")
+	assert.NotContains(t, string(data), `"key"`)
+
+	restored, err := ReadPlan(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, restored.HasHashedSecrets())
+
+	// they are taken from the manifest on apply
+	manifest := &config.Config{
+		Namespace:  "test",
+		Containers: map[string]*config.Container{"1": c1.Config},
+	}
+	if err := restored.ResolveSecrets(manifest); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, restored.HasHashedSecrets())
+
+	spec := find(restored.expected, c1.Name).Config
+	assert.Equal(t, "qwerty", spec.Env["DB_PASSWORD"])
+	assert.Equal(t, "key", spec.Secrets["db_key"].Value)
+
+	// and should be the same the plan was made with
+	restored, err = ReadPlan(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := &config.Container{
+		Env:     config.StringMap{"DB_PASSWORD": "123456"},
+		Secrets: c1.Config.Secrets,
+	}
+	manifest.Containers["1"] = changed
+	assert.Error(t, restored.ResolveSecrets(manifest))
+}
+
+func TestPlanApplyNetworks(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Networks = config.Networks{"test.backend": &config.NetworkEndpoint{}}
+
+	actions, err := NewDiff("test").Diff([]*Container{c1}, []*Container{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPlan("test", actions, []*Container{c1}, []*Container{})
+	plan.Networks = map[string]*config.Network{"backend": &config.Network{}}
+	plan.Volumes = map[string]*config.Volume{"data": &config.Volume{}}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := ReadPlan(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// networks and volumes are created before containers, the same way as by 'run'
+	client := clientMock{}
+	client.On("GetContainers").Return([]*Container{}, nil)
+	client.On("FetchImages", restored.expected, mock.Anything).Return(nil)
+	client.On("GetNetworks", "test").Return([]*Network{}, nil)
+	client.On("CreateNetwork", mock.Anything).Return(nil)
+	client.On("GetVolumes", "test").Return([]*Volume{}, nil)
+	client.On("CreateVolume", mock.Anything).Return(nil)
+	client.On("RunContainer", mock.Anything).Return(nil)
+
+	compose := &Compose{client: &client}
+	if err := compose.ApplyAction(restored); err != nil {
+		t.Fatal(err)
+	}
+	client.AssertExpectations(t)
+
+	assert.Equal(t, "test.backend", client.Calls[3].Arguments.Get(0).(*Network).Name.String())
+	assert.Equal(t, "test.data", client.Calls[5].Arguments.Get(0).(*Volume).Name.String())
+	assert.Equal(t, "RunContainer", client.Calls[6].Method)
+}
@@ -0,0 +1,172 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/grammarly/rocker-compose/src/compose/ansible"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPlanChanges(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Env = config.StringMap{"FOO": "bar"}
+	c1x := newContainer("test", "1")
+	c1x.Config.Env = config.StringMap{"FOO": "baz"}
+	c1x.State.Running = false
+
+	c2 := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "1"})
+	c2x := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "1"})
+	c3 := newContainer("test", "3")
+	c4x := newContainer("test", "4")
+
+	expected := []*Container{c1, c2, c3}
+	actual := []*Container{c1x, c2x, c4x}
+
+	actions, err := NewDiff("test").Diff(expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPlan("test", actions, expected, actual)
+
+	assert.Len(t, plan.Changes, 4)
+
+	assert.Equal(t, ChangeRecreate, plan.Changes[0].Type)
+	assert.Equal(t, c1, plan.Changes[0].Container)
+	assert.Equal(t, []config.FieldDiff{
+		{Field: "env", Old: "FOO: baz", New: "FOO: bar"},
+		{Field: "state", Old: "stopped", New: "running"},
+	}, plan.Changes[0].Diff)
+
+	// recreated because of dependency
+	assert.Equal(t, ChangeRecreate, plan.Changes[1].Type)
+	assert.Equal(t, c2, plan.Changes[1].Container)
+	assert.Empty(t, plan.Changes[1].Diff)
+
+	assert.Equal(t, ChangeCreate, plan.Changes[2].Type)
+	assert.Equal(t, c3, plan.Changes[2].Container)
+
+	assert.Equal(t, ChangeRemove, plan.Changes[3].Type)
+	assert.Equal(t, c4x, plan.Changes[3].Container)
+
+	var buf bytes.Buffer
+	if _, err := plan.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `~ test.1 (recreate)
+    env:
+      was:
+        FOO: baz
+      becomes:
+        FOO: bar
+    state: stopped => running
+~ test.2 (recreate)
+    (forced by recreation of a dependency)
++ test.3 (create)
+- test.4 (remove)
+
+Plan: 1 to create, 0 to update, 2 to recreate, 1 to remove.
+`, buf.String())
+}
+
+func TestPlanEmpty(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1x := newContainer("test", "1")
+
+	actions, err := NewDiff("test").Diff([]*Container{c1}, []*Container{c1x})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPlan("test", actions, []*Container{c1}, []*Container{c1x})
+	assert.True(t, plan.IsEmpty())
+}
+
+func TestPlanUpdate(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Memory = config.NewConfigMemoryFromInt64(512 * 1024 * 1024)
+	c1x := newContainer("test", "1")
+	c1x.ID = "c1x"
+	c1x.Config.Memory = config.NewConfigMemoryFromInt64(256 * 1024 * 1024)
+
+	expected := []*Container{c1}
+	actual := []*Container{c1x}
+
+	actions, err := NewDiff("test").Diff(expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPlan("test", actions, expected, actual)
+
+	var buf bytes.Buffer
+	if _, err := plan.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `~ test.1 (update)
+    memory: 268435456 => 536870912
+
+Plan: 0 to create, 1 to update, 0 to recreate, 0 to remove.
+`, buf.String())
+
+	// the saved plan should update the same existing container
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := ReadPlan(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := clientMock{}
+	client.On("UpdateContainer", mock.Anything).Return(nil)
+	assert.NoError(t, NewDockerClientRunner(&client).Run(restored.actions))
+	client.AssertExpectations(t)
+
+	updated := client.Calls[0].Arguments.Get(0).(*Container)
+	assert.Equal(t, "test.1", updated.Name.String())
+	assert.Equal(t, "c1x", updated.ID)
+	assert.EqualValues(t, 512*1024*1024, updated.Config.Memory.Int64())
+}
+
+func TestWritePlanUpdated(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1x := newContainer("test", "1")
+	c1x.ID = "abc"
+
+	client := clientMock{}
+	client.On("GetPulledImages").Return()
+	client.On("GetRemovedImages").Return()
+
+	compose := &Compose{
+		client:        &client,
+		executionPlan: []Action{NewUpdateContainerAction(c1, c1x)},
+	}
+
+	resp := compose.WritePlan(&ansible.Response{})
+	assert.True(t, resp.Changed)
+	assert.Equal(t, []ansible.ResponseContainer{{ID: "abc", Name: "test.1"}}, resp.Updated)
+}
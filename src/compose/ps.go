@@ -0,0 +1,137 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// ContainerInfo is the summary of the existing container managed by rocker-compose,
+// as printed by 'rocker-compose ps'
+type ContainerInfo struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	ID        string    `json:"id"`
+	Image     string    `json:"image"`
+	ImageID   string    `json:"image_id"`
+	State     string    `json:"state"`
+	ExitCode  int       `json:"exit_code"`
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime,omitempty"`
+	Ports     []string  `json:"ports"`
+	ComposeID string    `json:"rocker_compose_id"`
+}
+
+// NewContainerInfo makes the summary of the container loaded from docker
+func NewContainerInfo(container *Container) *ContainerInfo {
+	info := &ContainerInfo{
+		Name:      container.Name.Name,
+		Namespace: container.Name.Namespace,
+		ID:        container.ID,
+		ImageID:   container.ImageID,
+		ExitCode:  container.State.ExitCode,
+		StartedAt: container.State.StartedAt,
+		Ports:     []string{},
+	}
+
+	if container.Image != nil {
+		info.Image = container.Image.String()
+	}
+
+	inspect := container.container
+	if inspect == nil {
+		info.State = container.State.String()
+		return info
+	}
+
+	info.State = inspect.State.StateString()
+	if inspect.State.Running {
+		info.Uptime = units.HumanDuration(time.Now().UTC().Sub(inspect.State.StartedAt))
+	}
+	if inspect.Config != nil {
+		info.ComposeID = inspect.Config.Labels["rocker-compose-id"]
+	}
+	if inspect.NetworkSettings != nil {
+		info.Ports = publishedPorts(inspect.NetworkSettings.Ports)
+	}
+
+	return info
+}
+
+// PsAction implements 'rocker-compose ps'
+// It lists containers managed by rocker-compose in the given namespace,
+// or in all namespaces if it is empty. The manifest is not needed.
+func (compose *Compose) PsAction(ns string) ([]*ContainerInfo, error) {
+	containers, err := compose.client.GetContainers(false)
+	if err != nil {
+		return nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	infos := []*ContainerInfo{}
+	for _, container := range containers {
+		if ns == "" || container.Name.Namespace == ns {
+			infos = append(infos, NewContainerInfo(container))
+		}
+	}
+
+	sort.Sort(containerInfosByName(infos))
+
+	return infos, nil
+}
+
+// publishedPorts returns the sorted list of ports bound to the host,
+// in the same format as `docker ps` prints them, e.g. 0.0.0.0:8080->80/tcp
+func publishedPorts(ports map[docker.Port][]docker.PortBinding) []string {
+	result := []string{}
+	for port, bindings := range ports {
+		for _, binding := range bindings {
+			if binding.HostPort == "" {
+				continue
+			}
+			hostIP := binding.HostIP
+			if hostIP == "" {
+				hostIP = "0.0.0.0"
+			}
+			result = append(result, fmt.Sprintf("%s:%s->%s", hostIP, binding.HostPort, port))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// containerInfosByName implements sort.Interface to sort containers by namespace and name
+type containerInfosByName []*ContainerInfo
+
+func (c containerInfosByName) Len() int {
+	return len(c)
+}
+
+func (c containerInfosByName) Less(i, j int) bool {
+	if c[i].Namespace != c[j].Namespace {
+		return c[i].Namespace < c[j].Namespace
+	}
+	return c[i].Name < c[j].Name
+}
+
+func (c containerInfosByName) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
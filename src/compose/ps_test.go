@@ -0,0 +1,87 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPsContainerInfo(t *testing.T) {
+	started := time.Now().UTC().Add(-2 * time.Hour)
+	inspect := &docker.Container{
+		ID:    "4a8c0e73f2d1",
+		Name:  "/myapp.web",
+		Image: "sha256:aaa",
+		Config: &docker.Config{
+			Image:  "quay.io/web:1.2",
+			Labels: map[string]string{"rocker-compose-id": "abc"},
+		},
+		State: docker.State{Running: true, StartedAt: started},
+		NetworkSettings: &docker.NetworkSettings{
+			Ports: map[docker.Port][]docker.PortBinding{
+				"80/tcp":   {{HostIP: "0.0.0.0", HostPort: "8080"}},
+				"443/tcp":  {{HostIP: "127.0.0.1", HostPort: "8443"}},
+				"9000/tcp": nil,
+			},
+		},
+	}
+
+	container, err := NewContainerFromDocker(inspect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := NewContainerInfo(container)
+	assert.Equal(t, "web", info.Name)
+	assert.Equal(t, "myapp", info.Namespace)
+	assert.Equal(t, "quay.io/web:1.2", info.Image)
+	assert.Equal(t, "sha256:aaa", info.ImageID)
+	assert.Equal(t, "running", info.State)
+	assert.Equal(t, "2 hours", info.Uptime)
+	assert.Equal(t, "abc", info.ComposeID)
+	assert.Equal(t, []string{"0.0.0.0:8080->80/tcp", "127.0.0.1:8443->443/tcp"}, info.Ports)
+}
+
+func TestPsAction(t *testing.T) {
+	c1 := newContainer("b", "1")
+	c2 := newContainer("a", "2")
+	c3 := newContainer("a", "1")
+
+	client := clientMock{}
+	client.On("GetContainers").Return([]*Container{c1, c2, c3}, nil)
+	compose := &Compose{client: &client}
+
+	infos, err := compose.PsAction("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Namespace+"."+info.Name)
+	}
+	assert.Equal(t, []string{"a.1", "a.2", "b.1"}, names)
+
+	infos, err = compose.PsAction("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, infos, 1)
+}
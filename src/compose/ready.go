@@ -0,0 +1,276 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// Defaults of the ready probes, unless given in the spec
+const (
+	readyDefaultTimeout  = time.Second
+	readyDefaultInterval = time.Second
+	readyDefaultRetries  = 30
+)
+
+// waitReadiness waits for the started container to pass its healthcheck and ready probes.
+// It returns false if the container has neither of them, so there was nothing to wait for.
+func (client *DockerClient) waitReadiness(container *Container) (bool, error) {
+	healthy, err := client.waitHealthy(container)
+	if err != nil {
+		return true, err
+	}
+	if container.Config.Ready == nil {
+		return healthy, nil
+	}
+	return true, client.waitReady(container)
+}
+
+// waitReady runs the ready probes of the container until all of them pass.
+// Fails if the probes did not pass after the given number of retries or the container exited.
+func (client *DockerClient) waitReady(container *Container) error {
+	var (
+		ready    = container.Config.Ready
+		timeout  = readyDefaultTimeout
+		interval = readyDefaultInterval
+		retries  = readyDefaultRetries
+	)
+	if ready.Timeout != nil {
+		timeout = ready.Timeout.Duration()
+	}
+	if ready.Interval != nil {
+		interval = ready.Interval.Duration()
+	}
+	if ready.Retries != nil {
+		retries = *ready.Retries
+	}
+
+	log.Infof("Waiting for %s to become ready...", container.Name)
+
+	for attempt := 1; ; attempt++ {
+		inspect, err := client.Docker.InspectContainer(container.Name.String())
+		if err != nil {
+			return err
+		}
+		if !inspect.State.Running {
+			return fmt.Errorf("Container %s exited with code %d while waiting to become ready",
+				container.Name, inspect.State.ExitCode)
+		}
+
+		err = client.runReadyProbes(ready, inspect, container.Config.Networks.Names(), timeout)
+		if err == nil {
+			log.Infof("Container %s is ready", container.Name)
+			return nil
+		}
+		if attempt >= retries {
+			return fmt.Errorf("Container %s is not ready after %d attempts, error: %s", container.Name, attempt, err)
+		}
+
+		log.Debugf("Container %s is not ready yet (attempt %d of %d): %s", container.Name, attempt, retries, err)
+		time.Sleep(interval)
+	}
+}
+
+// runReadyProbes runs every probe of the ready check once. Networks are the
+// user-defined networks of the container spec, see readyAddress.
+func (client *DockerClient) runReadyProbes(ready *config.ReadyCheck, inspect *docker.Container, networks []string, timeout time.Duration) error {
+	dockerHost := remoteDockerHost(client.Docker.Endpoint())
+
+	if ready.TCP != nil {
+		addr, err := readyAddress(inspect, networks, *ready.TCP, dockerHost)
+		if err != nil {
+			return err
+		}
+		if err := probeTCP(addr, timeout); err != nil {
+			return err
+		}
+	}
+	if ready.HTTP != nil {
+		addr, err := readyAddress(inspect, networks, ready.HTTP.Port, dockerHost)
+		if err != nil {
+			return err
+		}
+		url := fmt.Sprintf("http://%s/%s", addr, strings.TrimPrefix(ready.HTTP.Path, "/"))
+		if err := probeHTTP(url, ready.HTTP.Status, timeout); err != nil {
+			return err
+		}
+	}
+	if len(ready.Exec) > 0 {
+		if err := client.probeExec(inspect.ID, ready.Exec, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readyAddress returns the address to reach the given port of the container.
+// For the local docker, i.e. dockerHost is empty, it is the container IP, if there is one:
+// in the default bridge network, in the first of the given user-defined networks or in any
+// other network; the published port otherwise, and the port of localhost for containers
+// that run in the host network. Container IPs of the remote docker host are not reachable
+// from here, so only published ports and the host network are probed on the dockerHost.
+func readyAddress(inspect *docker.Container, networks []string, port int, dockerHost string) (string, error) {
+	settings := inspect.NetworkSettings
+	if settings == nil {
+		settings = &docker.NetworkSettings{}
+	}
+
+	local := dockerHost == ""
+	if local {
+		dockerHost = "127.0.0.1"
+	}
+
+	ip := containerIP(settings, networks)
+	if ip != "" && local {
+		return net.JoinHostPort(ip, strconv.Itoa(port)), nil
+	}
+
+	for _, binding := range settings.Ports[docker.Port(fmt.Sprintf("%d/tcp", port))] {
+		host := binding.HostIP
+		if host == "" || host == "0.0.0.0" {
+			host = dockerHost
+		}
+		return net.JoinHostPort(host, binding.HostPort), nil
+	}
+
+	if ip != "" {
+		return "", fmt.Errorf("Port %d of the container is not published, ready probes of the remote docker host %s can reach only published ports, use exec probe instead", port, dockerHost)
+	}
+
+	return net.JoinHostPort(dockerHost, strconv.Itoa(port)), nil
+}
+
+// containerIP returns the IP of the container in the default bridge network,
+// in the first of the given user-defined networks or in any other network
+func containerIP(settings *docker.NetworkSettings, networks []string) string {
+	if settings.IPAddress != "" {
+		return settings.IPAddress
+	}
+
+	other := []string{}
+	for name := range settings.Networks {
+		other = append(other, name)
+	}
+	sort.Strings(other)
+
+	for _, name := range append(networks, other...) {
+		if network, ok := settings.Networks[name]; ok && network.IPAddress != "" {
+			return network.IPAddress
+		}
+	}
+
+	return ""
+}
+
+// remoteDockerHost returns the host of the docker endpoint if docker is reached
+// over the network, e.g. 10.0.0.1 for tcp://10.0.0.1:2376, or an empty string
+// for the local docker, i.e. unix socket or loopback address
+func remoteDockerHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "unix" {
+		return ""
+	}
+
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" || host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return ""
+	}
+
+	return host
+}
+
+// probeTCP checks that the given address accepts connections
+func probeTCP(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return fmt.Errorf("TCP probe of %s failed, error: %s", addr, err)
+	}
+	return conn.Close()
+}
+
+// probeHTTP makes a GET request and checks the response status;
+// any 2xx status is expected if the status is not given
+func probeHTTP(url string, status int, timeout time.Duration) error {
+	httpClient := &http.Client{Timeout: timeout}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("HTTP probe of %s failed, error: %s", url, err)
+	}
+	resp.Body.Close()
+
+	if (status == 0 && resp.StatusCode >= 200 && resp.StatusCode < 300) || resp.StatusCode == status {
+		return nil
+	}
+	return fmt.Errorf("HTTP probe of %s failed, unexpected status: %s", url, resp.Status)
+}
+
+// probeExec runs the command inside of the container and checks that it exited with zero code
+func (client *DockerClient) probeExec(id string, cmd []string, timeout time.Duration) error {
+	exec, err := client.Docker.CreateExec(docker.CreateExecOptions{
+		Container:    id,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to create exec probe, error: %s", err)
+	}
+
+	var output bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Docker.StartExec(exec.ID, docker.StartExecOptions{
+			OutputStream: &output,
+			ErrorStream:  &output,
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("Failed to run exec probe, error: %s", err)
+		}
+	case <-time.After(timeout):
+		return fmt.Errorf("Exec probe %q timed out after %s", strings.Join(cmd, " "), timeout)
+	}
+
+	inspect, err := client.Docker.InspectExec(exec.ID)
+	if err != nil {
+		return fmt.Errorf("Failed to inspect exec probe, error: %s", err)
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("Exec probe %q exited with code %d: %s",
+			strings.Join(cmd, " "), inspect.ExitCode, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
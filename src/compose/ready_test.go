@@ -0,0 +1,125 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadyAddress(t *testing.T) {
+	inspect := &docker.Container{
+		NetworkSettings: &docker.NetworkSettings{IPAddress: "172.17.0.5"},
+	}
+	assertReadyAddress(t, "172.17.0.5:8080", inspect, nil, "")
+
+	inspect.NetworkSettings = &docker.NetworkSettings{
+		Ports: map[docker.Port][]docker.PortBinding{
+			"8080/tcp": []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: "32768"}},
+		},
+	}
+	assertReadyAddress(t, "127.0.0.1:32768", inspect, nil, "")
+
+	// net: host
+	inspect.NetworkSettings = nil
+	assertReadyAddress(t, "127.0.0.1:8080", inspect, nil, "")
+}
+
+func TestReadyAddressNetworks(t *testing.T) {
+	// containers in user-defined networks have no IP in the default bridge
+	inspect := &docker.Container{
+		NetworkSettings: &docker.NetworkSettings{
+			Networks: map[string]docker.ContainerNetwork{
+				"myapp.backend":  {IPAddress: "172.20.0.3"},
+				"myapp.frontend": {IPAddress: "172.21.0.3"},
+				"myapp.empty":    {},
+			},
+		},
+	}
+	assertReadyAddress(t, "172.21.0.3:8080", inspect, []string{"myapp.frontend", "myapp.backend"}, "")
+	assertReadyAddress(t, "172.20.0.3:8080", inspect, []string{"myapp.empty"}, "")
+	assertReadyAddress(t, "172.20.0.3:8080", inspect, nil, "")
+}
+
+func TestReadyAddressRemote(t *testing.T) {
+	// container IPs of the remote docker host are not reachable, only published ports
+	inspect := &docker.Container{
+		NetworkSettings: &docker.NetworkSettings{
+			IPAddress: "172.17.0.5",
+			Ports: map[docker.Port][]docker.PortBinding{
+				"8080/tcp": []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: "32768"}},
+			},
+		},
+	}
+	assertReadyAddress(t, "10.0.0.1:32768", inspect, nil, "10.0.0.1")
+
+	_, err := readyAddress(inspect, nil, 9090, "10.0.0.1")
+	assert.Error(t, err)
+
+	// net: host
+	inspect.NetworkSettings = nil
+	assertReadyAddress(t, "10.0.0.1:8080", inspect, nil, "10.0.0.1")
+
+	assert.Equal(t, "10.0.0.1", remoteDockerHost("tcp://10.0.0.1:2376"))
+	assert.Equal(t, "docker.example.com", remoteDockerHost("https://docker.example.com:2376"))
+	assert.Equal(t, "", remoteDockerHost("tcp://127.0.0.1:2375"))
+	assert.Equal(t, "", remoteDockerHost("tcp://localhost:2375"))
+	assert.Equal(t, "", remoteDockerHost("unix:///var/run/docker.sock"))
+}
+
+func assertReadyAddress(t *testing.T, expected string, inspect *docker.Container, networks []string, dockerHost string) {
+	addr, err := readyAddress(inspect, networks, 8080, dockerHost)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, addr)
+	}
+}
+
+func TestReadyProbeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+
+	assert.NoError(t, probeTCP(addr, time.Second))
+
+	listener.Close()
+	assert.Error(t, probeTCP(addr, time.Second))
+}
+
+func TestReadyProbeHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	assert.NoError(t, probeHTTP(server.URL+"/health", 0, time.Second))
+	assert.NoError(t, probeHTTP(server.URL+"/health", 200, time.Second))
+	assert.NoError(t, probeHTTP(server.URL+"/", 404, time.Second))
+
+	err := probeHTTP(server.URL+"/", 0, time.Second)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status: 404 Not Found")
+}
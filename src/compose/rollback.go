@@ -0,0 +1,193 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker/src/imagename"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// removalRecorder is a Client that remembers existing containers removed
+// during the run, so they can be restored if the run fails
+type removalRecorder struct {
+	Client
+
+	actual  []*Container
+	removed []*Container
+	mu      sync.Mutex
+}
+
+// newRemovalRecorder wraps the client; only removals of the given
+// existing containers are recorded, temporary ones are not restored
+func newRemovalRecorder(client Client, actual []*Container) *removalRecorder {
+	return &removalRecorder{
+		Client: client,
+		actual: actual,
+	}
+}
+
+// RemoveContainer removes a container and remembers it if it was one of existing ones
+func (r *removalRecorder) RemoveContainer(container *Container) error {
+	if err := r.Client.RemoveContainer(container); err != nil {
+		return err
+	}
+
+	for _, c := range r.actual {
+		if c == container {
+			r.mu.Lock()
+			r.removed = append(r.removed, container)
+			r.mu.Unlock()
+			break
+		}
+	}
+
+	return nil
+}
+
+// Removed returns the list of removed containers in order of removal
+func (r *removalRecorder) Removed() []*Container {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.removed
+}
+
+// removedContainers returns existing containers that the actions are going to remove
+func removedContainers(actions []Action) []*Container {
+	removed := []*Container{}
+	WalkActions(actions, func(action Action) {
+		switch a := action.(type) {
+		case *removeContainer:
+			removed = append(removed, a.container)
+		case *replaceContainer:
+			removed = append(removed, a.actual)
+		case *migrateContainer:
+			removed = append(removed, a.actual)
+		}
+	})
+	return removed
+}
+
+// createdContainer returns the container that the action creates, if any
+func createdContainer(action Action) *Container {
+	switch a := action.(type) {
+	case *runContainer:
+		return a.container
+	case *replaceContainer:
+		return a.container
+	case *migrateContainer:
+		return a.container
+	}
+	return nil
+}
+
+// withoutContainers returns the list of containers except the ones with given names
+func withoutContainers(containers []*Container, names map[string]struct{}) []*Container {
+	result := []*Container{}
+	for _, container := range containers {
+		if _, ok := names[container.Name.String()]; !ok {
+			result = append(result, container)
+		}
+	}
+	return result
+}
+
+// rollbackSpecs returns specs to restore the given containers from, if the run fails.
+// Since contents of secrets are only taken from the manifest, it is checked before the run
+// starts, and containers that cannot be restored are reported and left out.
+func (compose *Compose) rollbackSpecs(containers []*Container) map[*Container]*config.Container {
+	specs := map[*Container]*config.Container{}
+	for _, container := range containers {
+		spec, err := container.specWithSecrets(compose.Manifest)
+		if err != nil {
+			log.Errorf("Container %s cannot be restored if the run fails, error: %s", container.Name, err)
+			continue
+		}
+		specs[container] = spec
+	}
+	return specs
+}
+
+// rollback restores the containers that were removed by the failed run from the given
+// specs and the image ids they were running. Containers that took their names are removed
+// first. Containers are restored in the order of removal, so dependencies come first.
+// Returns the list of restored containers.
+func (compose *Compose) rollback(removed []*Container, specs map[*Container]*config.Container) (restored []*Container, err error) {
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	current, err := compose.client.GetContainers(false)
+	if err != nil {
+		return nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	failed := []string{}
+
+	for _, old := range removed {
+		spec, ok := specs[old]
+		if !ok {
+			log.Warnf("Cannot restore container %s, see the error above", old.Name)
+			failed = append(failed, old.Name.String())
+			continue
+		}
+
+		container := &Container{
+			Name:    old.Name,
+			Image:   old.Image,
+			ImageID: old.ImageID,
+			State: &ContainerState{
+				Running: spec.State.Bool(),
+			},
+			Config: spec,
+		}
+		// run exactly the same image, the tag may point to another one by now
+		if old.ImageID != "" {
+			container.Image = imagename.NewFromString(old.ImageID)
+		}
+
+		if err := compose.restoreContainer(container, find(current, old.Name)); err != nil {
+			log.Errorf("Failed to restore container %s, error: %s", old.Name, err)
+			failed = append(failed, old.Name.String())
+			continue
+		}
+
+		restored = append(restored, container)
+	}
+
+	if len(failed) > 0 {
+		return restored, fmt.Errorf("Failed to restore containers: %s", strings.Join(failed, ", "))
+	}
+
+	return restored, nil
+}
+
+// restoreContainer runs the container removing the one
+// that has the same name, if there is any
+func (compose *Compose) restoreContainer(container *Container, existing *Container) error {
+	if existing != nil {
+		if err := compose.client.RemoveContainer(existing); err != nil {
+			return err
+		}
+	}
+	return compose.client.RunContainer(container)
+}
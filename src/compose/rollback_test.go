@@ -0,0 +1,151 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker/src/imagename"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRollbackOnFailure(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Env = config.StringMap{"VERSION": "2"}
+	c1x := newContainer("test", "1")
+	c1x.ID = "c1x"
+	c1x.Image = imagename.NewFromString("quay.io/app:1")
+	c1x.ImageID = "sha256:aaa"
+
+	c2 := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "1"})
+	c2.Config.Env = config.StringMap{"VERSION": "2"}
+	c2x := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "1"})
+	c2x.ID = "c2x"
+
+	actual := []*Container{c1x, c2x}
+	actions, err := NewDiff("test").Diff([]*Container{c1, c2}, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test.1 is recreated, but test.2 fails to start
+	client := clientMock{}
+	client.On("RemoveContainer", c1x).Return(nil)
+	client.On("RunContainer", c1).Return(nil)
+	client.On("RemoveContainer", c2x).Return(nil)
+	client.On("RunContainer", c2).Return(fmt.Errorf("exited"))
+	client.On("GetContainers").Return(nil)
+	client.On("RunContainer", mock.Anything).Return(nil)
+
+	compose := &Compose{client: &client, Rollback: true}
+
+	err = compose.run(actions, actual)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exited; rolled back, restored containers: test.1, test.2")
+
+	// both old containers are restored in the order of removal, by image id
+	restored := []*Container{}
+	for _, call := range client.Calls[5:] {
+		assert.Equal(t, "RunContainer", call.Method)
+		restored = append(restored, call.Arguments.Get(0).(*Container))
+	}
+	assert.Len(t, restored, 2)
+	assert.Equal(t, "test.1", restored[0].Name.String())
+	assert.Equal(t, "sha256:aaa", restored[0].Image.String())
+	assert.True(t, restored[0].State.Running)
+	assert.Equal(t, "test.2", restored[1].Name.String())
+}
+
+func TestRollbackSecrets(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Config.Env = config.StringMap{"VERSION": "2"}
+	c1.Config.Secrets = config.Secrets{"db_key": &config.Secret{Value: "key"}}
+
+	// existing containers keep only hashes of secrets in their labels
+	c1x := newContainer("test", "1")
+	c1x.ID = "c1x"
+	c1x.Config = (&config.Container{Secrets: config.Secrets{"db_key": &config.Secret{Value: "key"}}}).HashSecrets()
+	c2x := newContainer("test", "2")
+	c2x.ID = "c2x"
+	c2x.Config = (&config.Container{Secrets: config.Secrets{"api_token": &config.Secret{Value: "abc"}}}).HashSecrets()
+
+	actual := []*Container{c1x, c2x}
+	actions, err := NewDiff("test").Diff([]*Container{c1}, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test.1 is recreated but fails to start, test.2 is removed
+	client := clientMock{}
+	client.On("RemoveContainer", c1x).Return(nil)
+	client.On("RemoveContainer", c2x).Return(nil)
+	client.On("RunContainer", c1).Return(fmt.Errorf("exited"))
+	client.On("GetContainers").Return(nil)
+	client.On("RunContainer", mock.Anything).Return(nil)
+
+	// the manifest has the secret of test.1 only
+	compose := &Compose{
+		client:   &client,
+		Rollback: true,
+		Manifest: &config.Config{
+			Namespace:  "test",
+			Containers: map[string]*config.Container{"1": c1.Config},
+		},
+	}
+
+	err = compose.run(actions, actual)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "rollback failed, error: Failed to restore containers: test.2")
+
+	restored := []*Container{}
+	for _, call := range client.Calls {
+		if call.Method == "RunContainer" && call.Arguments.Get(0) != c1 {
+			restored = append(restored, call.Arguments.Get(0).(*Container))
+		}
+	}
+	assert.Len(t, restored, 1)
+	assert.Equal(t, "test.1", restored[0].Name.String())
+	assert.Equal(t, "key", restored[0].Config.Secrets["db_key"].Value)
+}
+
+func TestRollbackPinImages(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.Image = imagename.NewFromString("quay.io/app:1")
+	c1.ImageID = "sha256:bbb"
+	c2 := newContainer("test", "2")
+	c2.Image = imagename.NewFromString("quay.io/worker:1")
+	c2.ImageID = "sha256:ccc"
+
+	// the tag of test.1 points to another image by now
+	client := clientMock{}
+	client.On("FetchImages", []*Container{c1}, mock.Anything).Return(nil)
+
+	compose := &Compose{
+		client:       &client,
+		Manifest:     &config.Config{Namespace: "test"},
+		pinnedImages: map[string]string{"1": "sha256:aaa", "2": "sha256:ccc"},
+	}
+
+	assert.NoError(t, compose.pinImages([]*Container{c1, c2}))
+	client.AssertExpectations(t)
+
+	assert.Equal(t, "sha256:aaa", c1.Image.String())
+	assert.Equal(t, "quay.io/worker:1", c2.Image.String())
+}
@@ -0,0 +1,106 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+)
+
+// defaultSecretMode is the mode of secret files, unless given in the manifest
+const defaultSecretMode = 0444
+
+// uploadSecrets puts secrets of the container spec to the created container
+// before it starts, they end up in the writable layer of the container
+func (client *DockerClient) uploadSecrets(container *Container) error {
+	archive, err := secretsArchive(container.Config.Secrets)
+	if err != nil {
+		return fmt.Errorf("Failed to prepare secrets of container %s, error: %s", container.Name, err)
+	}
+
+	log.Infof("Upload %d secrets to container %s", len(container.Config.Secrets), container.Name)
+
+	if err := client.Docker.UploadToContainer(container.ID, docker.UploadToContainerOptions{
+		InputStream: archive,
+		Path:        "/",
+	}); err != nil {
+		return fmt.Errorf("Failed to upload secrets to container %s, error: %s", container.Name, err)
+	}
+
+	return nil
+}
+
+// secretsArchive makes a tar archive of secret files named by their target paths without
+// the leading slash. Contents of secrets of specs read from container labels are unknown,
+// since the labels keep only hashes, so such containers cannot be created again.
+func secretsArchive(secrets config.Secrets) (*bytes.Buffer, error) {
+	names := []string{}
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	for _, name := range names {
+		secret := secrets[name]
+		if secret.Value == "" && secret.Hash != "" {
+			return nil, fmt.Errorf("content of secret %s is unknown, only its hash is stored in the container label", name)
+		}
+
+		mode := int64(defaultSecretMode)
+		if secret.Mode != nil {
+			mode = *secret.Mode
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    strings.TrimPrefix(secret.Target, "/"),
+			Mode:    mode,
+			Size:    int64(len(secret.Value)),
+			ModTime: time.Now(),
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(secret.Value)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// manifestSpec returns the spec of the container from the manifest
+// or nil if there is no manifest or no such container in it
+func manifestSpec(manifest *config.Config, name *config.ContainerName) *config.Container {
+	if manifest == nil || name.Namespace != manifest.Namespace {
+		return nil
+	}
+	return manifest.Containers[name.Name]
+}
@@ -0,0 +1,64 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretsArchive(t *testing.T) {
+	mode := int64(0400)
+	secrets := config.Secrets{
+		"db_password": &config.Secret{Value: "qwerty", Target: "/run/secrets/db_password"},
+		"api_token":   &config.Secret{Value: "abc", Target: "/etc/app/token", Mode: &mode},
+	}
+
+	archive, err := secretsArchive(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(archive)
+	files := map[string]string{}
+	modes := map[string]int64{}
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(data)
+		modes[header.Name] = header.Mode
+	}
+
+	assert.Equal(t, map[string]string{"run/secrets/db_password": "qwerty", "etc/app/token": "abc"}, files)
+	assert.Equal(t, map[string]int64{"run/secrets/db_password": 0444, "etc/app/token": 0400}, modes)
+
+	// specs of existing containers keep only hashes
+	_, err = secretsArchive(config.Secrets{
+		"db_password": &config.Secret{Target: "/run/secrets/db_password", Hash: "secret:sha256:aaa:bbb"},
+	})
+	assert.EqualError(t, err, "content of secret db_password is unknown, only its hash is stored in the container label")
+}
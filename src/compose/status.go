@@ -0,0 +1,182 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+)
+
+// SyncStatus tells how the existing container relates to the manifest
+type SyncStatus string
+
+const (
+	// StatusInSync means the container exists and matches the manifest
+	StatusInSync SyncStatus = "in-sync"
+	// StatusMissing means the container of the manifest does not exist
+	StatusMissing SyncStatus = "missing"
+	// StatusDrifted means the container differs from the manifest
+	StatusDrifted SyncStatus = "drifted"
+	// StatusStopped means the container matches the manifest, but is not running
+	StatusStopped SyncStatus = "stopped"
+	// StatusOrphaned means the container of the namespace is not in the manifest anymore
+	StatusOrphaned SyncStatus = "orphaned"
+	// StatusUnmanaged means the container has a name of the namespace,
+	// but was not created by rocker-compose, e.g. by plain `docker run`
+	StatusUnmanaged SyncStatus = "unmanaged"
+)
+
+// Status is the drift report of the namespace: the list of statuses
+// of containers of the manifest and existing containers of the namespace
+type Status struct {
+	Namespace  string             `json:"namespace"`
+	Containers []*ContainerStatus `json:"containers"`
+}
+
+// ContainerStatus is a single container of the Status. For drifted containers
+// Diff holds every property that differs from the manifest.
+type ContainerStatus struct {
+	Name   string             `json:"name"`
+	ID     string             `json:"id,omitempty"`
+	Status SyncStatus         `json:"status"`
+	Diff   []config.FieldDiff `json:"diff,omitempty"`
+}
+
+// NewStatus compares containers of the manifest with existing ones, which may
+// include containers not created by rocker-compose, and makes the drift report
+func NewStatus(ns string, expected, actual []*Container) *Status {
+	status := &Status{
+		Namespace:  ns,
+		Containers: []*ContainerStatus{},
+	}
+
+	for _, container := range expected {
+		s := &ContainerStatus{Name: container.Name.String()}
+		status.Containers = append(status.Containers, s)
+
+		existing := find(actual, container.Name)
+		if existing == nil {
+			s.Status = StatusMissing
+			continue
+		}
+
+		s.ID = existing.ID
+
+		if existing.Config == nil {
+			s.Status = StatusUnmanaged
+			continue
+		}
+
+		s.Diff = container.Differences(existing)
+		switch {
+		case len(s.Diff) == 0:
+			s.Status = StatusInSync
+		case len(s.Diff) == 1 && s.Diff[0].Field == "state" && container.State.Running:
+			s.Status = StatusStopped
+			s.Diff = nil
+		default:
+			s.Status = StatusDrifted
+		}
+	}
+
+	for _, container := range actual {
+		if container.Name.Namespace != ns || find(expected, container.Name) != nil {
+			continue
+		}
+
+		s := &ContainerStatus{
+			Name:   container.Name.String(),
+			ID:     container.ID,
+			Status: StatusOrphaned,
+		}
+		if container.Config == nil {
+			s.Status = StatusUnmanaged
+		}
+		status.Containers = append(status.Containers, s)
+	}
+
+	sort.Sort(containerStatusesByName(status.Containers))
+
+	return status
+}
+
+// InSync returns true if all containers of the namespace match the manifest
+func (s *Status) InSync() bool {
+	return s.Count(StatusInSync) == len(s.Containers)
+}
+
+// Count returns the number of containers with the given status
+func (s *Status) Count(status SyncStatus) (n int) {
+	for _, container := range s.Containers {
+		if container.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteTo writes the human readable representation of the report to the writer
+func (s *Status) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTAINER\tID\tSTATUS")
+	for _, container := range s.Containers {
+		fmt.Fprintf(tw, "%s\t%.12s\t%s\n", container.Name, container.ID, container.Status)
+	}
+	tw.Flush()
+
+	for _, container := range s.Containers {
+		if len(container.Diff) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n%s:\n", container.Name)
+		for _, d := range container.Diff {
+			buf.WriteString(formatFieldDiff(d))
+		}
+	}
+
+	if s.InSync() {
+		fmt.Fprintf(&buf, "\nNamespace %s is in sync with the manifest.\n", s.Namespace)
+	} else {
+		fmt.Fprintf(&buf, "\nDrift: %d missing, %d drifted, %d stopped, %d orphaned, %d unmanaged.\n",
+			s.Count(StatusMissing), s.Count(StatusDrifted), s.Count(StatusStopped),
+			s.Count(StatusOrphaned), s.Count(StatusUnmanaged))
+	}
+
+	return buf.WriteTo(w)
+}
+
+// containerStatusesByName implements sort.Interface to sort statuses by container name
+type containerStatusesByName []*ContainerStatus
+
+func (c containerStatusesByName) Len() int {
+	return len(c)
+}
+
+func (c containerStatusesByName) Less(i, j int) bool {
+	return c[i].Name < c[j].Name
+}
+
+func (c containerStatusesByName) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
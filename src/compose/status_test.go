@@ -0,0 +1,79 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	synced := newContainer("test", "synced")
+	syncedx := newContainer("test", "synced")
+	syncedx.ID = "aaa"
+
+	missing := newContainer("test", "missing")
+
+	drifted := newContainer("test", "drifted")
+	drifted.Config.Env = config.StringMap{"VERSION": "2"}
+	driftedx := newContainer("test", "drifted")
+
+	stopped := newContainer("test", "stopped")
+	stoppedx := newContainer("test", "stopped")
+	stoppedx.State.Running = false
+
+	// someone did `docker run --name test.manual` over the managed container
+	manual := newContainer("test", "manual")
+	manualx := newContainer("test", "manual")
+	manualx.Config = nil
+
+	orphaned := newContainer("test", "orphaned")
+	external := newContainer("other", "app")
+
+	expected := []*Container{synced, missing, drifted, stopped, manual}
+	actual := []*Container{syncedx, driftedx, stoppedx, manualx, orphaned, external}
+
+	status := NewStatus("test", expected, actual)
+
+	statuses := map[string]SyncStatus{}
+	for _, c := range status.Containers {
+		statuses[c.Name] = c.Status
+	}
+	assert.Equal(t, map[string]SyncStatus{
+		"test.synced":   StatusInSync,
+		"test.missing":  StatusMissing,
+		"test.drifted":  StatusDrifted,
+		"test.stopped":  StatusStopped,
+		"test.manual":   StatusUnmanaged,
+		"test.orphaned": StatusOrphaned,
+	}, statuses)
+
+	assert.False(t, status.InSync())
+	assert.Equal(t, "test.drifted", status.Containers[0].Name)
+	assert.Equal(t, []config.FieldDiff{{Field: "env", Old: "{}", New: "VERSION: \"2\""}}, status.Containers[0].Diff)
+
+	var buf bytes.Buffer
+	if _, err := status.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, buf.String(), "Drift: 1 missing, 1 drifted, 1 stopped, 1 orphaned, 1 unmanaged.")
+
+	assert.True(t, NewStatus("test", []*Container{synced}, []*Container{syncedx}).InSync())
+}
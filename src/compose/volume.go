@@ -0,0 +1,147 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/go-yaml/yaml"
+)
+
+// Volume is a named docker volume managed by rocker-compose.
+// Volumes are named the same way as containers, i.e. namespace.name
+type Volume struct {
+	Name   *config.ContainerName
+	Config *config.Volume
+}
+
+// GetVolumesFromConfig returns the list of Volume objects from
+// a spec Config object, sorted by name
+func GetVolumesFromConfig(cfg *config.Config) []*Volume {
+	volumes := []*Volume{}
+	for name, volumeConfig := range cfg.Volumes {
+		if volumeConfig == nil {
+			volumeConfig = &config.Volume{}
+		}
+		volumes = append(volumes, &Volume{
+			Name:   config.NewContainerName(cfg.Namespace, name),
+			Config: volumeConfig,
+		})
+	}
+	sort.Sort(volumesByName(volumes))
+	return volumes
+}
+
+// NewVolumeFromDocker converts a volume object given by docker client
+// to a local Volume object. The spec is read from the label
+// that rocker-compose assigns on creation.
+func NewVolumeFromDocker(dockerVolume *docker.Volume) (*Volume, error) {
+	volume := &Volume{
+		Name:   config.NewContainerNameFromString(dockerVolume.Name),
+		Config: &config.Volume{},
+	}
+	if err := yaml.Unmarshal([]byte(dockerVolume.Labels["rocker-compose-config"]), volume.Config); err != nil {
+		return nil, fmt.Errorf("Failed to parse spec of volume %s, error: %s", dockerVolume.Name, err)
+	}
+	return volume, nil
+}
+
+// String returns volume name
+func (v Volume) String() string {
+	return v.Name.String()
+}
+
+// IsEqualTo returns true if both volumes have the same spec
+func (v *Volume) IsEqualTo(b *Volume) bool {
+	specA, errA := yaml.Marshal(v.Config)
+	specB, errB := yaml.Marshal(b.Config)
+	return errA == nil && errB == nil && string(specA) == string(specB)
+}
+
+// CreateVolumeOptions returns create configuration eatable by go-dockerclient
+func (v *Volume) CreateVolumeOptions() (*docker.CreateVolumeOptions, error) {
+	yamlData, err := yaml.Marshal(v.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	for k, val := range v.Config.Labels {
+		labels[k] = val
+	}
+	labels["rocker-compose-namespace"] = v.Name.Namespace
+	labels["rocker-compose-config"] = string(yamlData)
+
+	opts := &docker.CreateVolumeOptions{
+		Name:       v.Name.String(),
+		Driver:     "local",
+		DriverOpts: v.Config.DriverOpts,
+		Labels:     labels,
+	}
+	if v.Config.Driver != nil {
+		opts.Driver = *v.Config.Driver
+	}
+
+	return opts, nil
+}
+
+// diffVolumes compares the volumes of the manifest with existing ones and returns
+// those to be created, those which spec has changed, and those not in the manifest anymore
+func diffVolumes(expected, actual []*Volume) (create, changed, obsolete []*Volume) {
+	for _, volume := range expected {
+		existing := findVolume(actual, volume.Name)
+		if existing == nil {
+			create = append(create, volume)
+		} else if !volume.IsEqualTo(existing) {
+			changed = append(changed, volume)
+		}
+	}
+	for _, volume := range actual {
+		if findVolume(expected, volume.Name) == nil {
+			obsolete = append(obsolete, volume)
+		}
+	}
+	return create, changed, obsolete
+}
+
+func findVolume(volumes []*Volume, name *config.ContainerName) *Volume {
+	for _, volume := range volumes {
+		if volume.Name.IsEqualTo(name) {
+			return volume
+		}
+	}
+	return nil
+}
+
+// volumesByName implements sort.Interface to sort volumes by name
+type volumesByName []*Volume
+
+func (v volumesByName) Len() int {
+	return len(v)
+}
+
+func (v volumesByName) Less(i, j int) bool {
+	return v[i].Name.String() < v[j].Name.String()
+}
+
+func (v volumesByName) Swap(i, j int) {
+	v[i], v[j] = v[j], v[i]
+}
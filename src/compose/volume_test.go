@@ -0,0 +1,140 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeCreateOptions(t *testing.T) {
+	volume := &Volume{
+		Name: config.NewContainerName("test", "data"),
+		Config: &config.Volume{
+			DriverOpts: config.StringMap{"type": "tmpfs"},
+		},
+	}
+
+	opts, err := volume.CreateVolumeOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "test.data", opts.Name)
+	assert.Equal(t, "local", opts.Driver)
+	assert.Equal(t, map[string]string{"type": "tmpfs"}, opts.DriverOpts)
+	assert.Equal(t, "test", opts.Labels["rocker-compose-namespace"])
+	assert.Contains(t, opts.Labels["rocker-compose-config"], "type: tmpfs")
+}
+
+func TestVolumeEnsure(t *testing.T) {
+	driver := "rexray"
+	cfg := &config.Config{
+		Namespace: "test",
+		Volumes: map[string]*config.Volume{
+			"data":  &config.Volume{},
+			"db":    &config.Volume{Driver: &driver},
+			"cache": nil,
+		},
+	}
+	expected := GetVolumesFromConfig(cfg)
+
+	actual := []*Volume{
+		&Volume{Name: config.NewContainerName("test", "db"), Config: &config.Volume{}},
+		&Volume{Name: config.NewContainerName("test", "old"), Config: &config.Volume{}},
+	}
+
+	client := clientMock{}
+	client.On("GetVolumes", "test").Return(actual, nil)
+	client.On("CreateVolume", expected[0]).Return(nil)
+	client.On("CreateVolume", expected[1]).Return(nil)
+
+	compose := &Compose{client: &client, Manifest: cfg}
+
+	// test.db has changed, but is never recreated
+	obsolete, err := compose.ensureVolumes(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*Volume{actual[1]}, obsolete)
+	assert.Len(t, client.Calls, 3)
+
+	// obsolete volumes are kept unless asked
+	compose.pruneVolumes(obsolete)
+	assert.Len(t, client.Calls, 3)
+
+	client.On("RemoveVolume", actual[1]).Return(nil)
+	compose.PruneVolumes = true
+	compose.pruneVolumes(obsolete)
+	client.AssertExpectations(t)
+}
+
+func TestVolumeLabels(t *testing.T) {
+	// the spec is kept in labels of the volume, which docker gives back in the list
+	var labels map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /volumes/create":
+			opts := struct{ Labels map[string]string }{}
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				t.Error(err)
+			}
+			labels = opts.Labels
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Name": "test.data"}`))
+		case "GET /volumes":
+			data, _ := json.Marshal(map[string]interface{}{
+				"Volumes": []map[string]interface{}{{"Name": "test.data", "Labels": labels}},
+			})
+			w.Write(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dockerCli, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &DockerClient{Docker: dockerCli}
+
+	driver := "rexray"
+	volume := &Volume{
+		Name:   config.NewContainerName("test", "data"),
+		Config: &config.Volume{Driver: &driver, Labels: config.StringMap{"team": "db"}},
+	}
+	if err := client.CreateVolume(volume); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test", labels["rocker-compose-namespace"])
+	assert.Equal(t, "db", labels["team"])
+
+	volumes, err := client.GetVolumes("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, volumes, 1) {
+		assert.True(t, volume.IsEqualTo(volumes[0]))
+	}
+}
@@ -0,0 +1,271 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+)
+
+const (
+	// watchMaxBackoff is the longest delay between reconciliations after repeated failures,
+	// unless the reconcile interval itself is longer
+	watchMaxBackoff = 5 * time.Minute
+	// watchSettleDelay is the time to wait for more events after the first one,
+	// so a burst of events, e.g. die and destroy, results in a single reconciliation
+	watchSettleDelay = time.Second
+	// watchRelistenDelay is the delay between attempts to listen to Docker events
+	// again after the event stream is closed, e.g. when the daemon restarts
+	watchRelistenDelay = 5 * time.Second
+)
+
+// watchEvents is the list of container events that may mean a drift from the manifest
+var watchEvents = map[string]bool{
+	"die":     true,
+	"destroy": true,
+	"oom":     true,
+	"pause":   true,
+	"rename":  true,
+	"update":  true,
+}
+
+// WatchContainers listens to Docker events and sends the name of the container
+// of the namespace to the channel every time it dies, gets removed, renamed, etc.
+// Sending does not block, so some names may be dropped if the receiver is busy.
+// If the event stream is closed, listening starts again. Listening stops once
+// the stop channel is closed.
+func (client *DockerClient) WatchContainers(ns string, changes chan<- string, stop <-chan struct{}) error {
+	// The code is partially borrowed from listenReAttach
+	eventChan := make(chan *docker.APIEvents, 100)
+
+	if err := client.Docker.AddEventListener(eventChan); err != nil {
+		return fmt.Errorf("Failed to start listening for Docker events, error: %s", err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-stop:
+				client.Docker.RemoveEventListener(eventChan)
+				return
+
+			case event := <-eventChan:
+				if event == nil {
+					log.Warnf("Docker event stream is closed, listening again")
+					if eventChan = client.relistenEvents(eventChan, stop); eventChan == nil {
+						return
+					}
+					break
+				}
+
+				name := watchEventContainer(ns, event)
+				if name == "" {
+					break
+				}
+
+				log.Debugf("Got event of container %s", name)
+
+				select {
+				case changes <- name:
+				default:
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// relistenEvents replaces the closed event listener with a new one, retrying every
+// watchRelistenDelay until it succeeds. Returns nil if the stop channel is closed meanwhile.
+func (client *DockerClient) relistenEvents(closed chan *docker.APIEvents, stop <-chan struct{}) chan *docker.APIEvents {
+	client.Docker.RemoveEventListener(closed)
+
+	for {
+		eventChan := make(chan *docker.APIEvents, 100)
+		err := client.Docker.AddEventListener(eventChan)
+		if err == nil {
+			return eventChan
+		}
+		log.Errorf("Failed to listen for Docker events again, next attempt in %s, error: %s", watchRelistenDelay, err)
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(watchRelistenDelay):
+		}
+	}
+}
+
+// watchEventContainer returns the name of the container of the namespace
+// the event is about, or an empty string if the event is of no interest
+func watchEventContainer(ns string, event *docker.APIEvents) string {
+	if event.Type != "" && event.Type != "container" {
+		return ""
+	}
+
+	action := event.Action
+	if action == "" {
+		action = event.Status
+	}
+	if !watchEvents[action] {
+		return ""
+	}
+
+	// renamed container does not belong to the namespace under its new name
+	for _, attr := range []string{"name", "oldName"} {
+		name := config.NewContainerNameFromString(event.Actor.Attributes[attr])
+		if name.Name != "" && name.Namespace == ns {
+			return name.String()
+		}
+	}
+
+	return ""
+}
+
+// WatchAction implements 'rocker-compose watch'
+// It keeps the manifest applied: containers are reconciled with the manifest
+// every interval and every time a container of the namespace changes.
+// Repeated failures delay the next attempt exponentially, see watchBackoff.
+// Returns once the stop channel is closed.
+func (compose *Compose) WatchAction(interval time.Duration, stop <-chan struct{}) error {
+	ns := compose.Manifest.Namespace
+	changes := make(chan string, 1)
+
+	if err := compose.client.WatchContainers(ns, changes, stop); err != nil {
+		return err
+	}
+
+	log.Infof("Watching namespace %s, reconcile interval %s", ns, interval)
+
+	failures := 0
+
+	for {
+		delay := interval
+
+		if err := compose.reconcile(); err != nil {
+			failures++
+			delay = watchBackoff(interval, failures)
+			log.Errorf("Failed to reconcile namespace %s (%d failures in a row), next attempt in %s, error: %s",
+				ns, failures, delay, err)
+		} else {
+			failures = 0
+		}
+
+		// events caused by the reconciliation itself are of no interest
+		drainWatchChanges(changes)
+
+		// do not react to events while backing off
+		events := (<-chan string)(changes)
+		if failures > 0 {
+			events = nil
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-stop:
+			timer.Stop()
+			log.Infof("Stopped watching namespace %s", ns)
+			return nil
+		case <-timer.C:
+			log.Debugf("Reconciling namespace %s by interval", ns)
+		case name := <-events:
+			timer.Stop()
+			log.Infof("Container %s changed, reconciling namespace %s", name, ns)
+			time.Sleep(watchSettleDelay)
+			drainWatchChanges(changes)
+		}
+	}
+}
+
+// reconcile brings networks, volumes and containers of the namespace to the state
+// of the manifest, the same way as RunAction does; containers only if there is any drift.
+// Obsolete volumes are kept, since watch does not prune them.
+func (compose *Compose) reconcile() error {
+	expected, actual, executionPlan, err := compose.buildExecutionPlan()
+	if err != nil {
+		return err
+	}
+
+	obsoleteNetworks, err := compose.ensureNetworks(compose.Manifest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := compose.ensureVolumes(compose.Manifest); err != nil {
+		return err
+	}
+
+	plan := NewPlan(compose.Manifest.Namespace, executionPlan, expected, actual)
+	if plan.IsEmpty() {
+		log.Debugf("No drift in namespace %s", compose.Manifest.Namespace)
+		compose.removeNetworks(obsoleteNetworks)
+		return nil
+	}
+
+	names := []string{}
+	for _, change := range plan.Changes {
+		names = append(names, fmt.Sprintf("%s (%s)", change.Container.Name, change.Type))
+	}
+	log.Infof("Correcting drift in namespace %s: %s", compose.Manifest.Namespace, strings.Join(names, ", "))
+
+	compose.executionPlan = executionPlan
+
+	if err := compose.run(executionPlan, actual); err != nil {
+		return fmt.Errorf("Execution failed with, error: %s", err)
+	}
+
+	compose.removeNetworks(obsoleteNetworks)
+
+	return nil
+}
+
+// watchBackoff returns the delay before the next reconciliation after the given
+// number of failures in a row: the interval doubles with every failure up to watchMaxBackoff
+func watchBackoff(interval time.Duration, failures int) time.Duration {
+	max := watchMaxBackoff
+	if interval > max {
+		max = interval
+	}
+
+	delay := interval
+	for i := 0; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	return delay
+}
+
+// drainWatchChanges discards pending container changes
+func drainWatchChanges(changes chan string) {
+	for {
+		select {
+		case <-changes:
+		default:
+			return
+		}
+	}
+}
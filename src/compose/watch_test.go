@@ -0,0 +1,107 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWatchBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, watchBackoff(30*time.Second, 0))
+	assert.Equal(t, time.Minute, watchBackoff(30*time.Second, 1))
+	assert.Equal(t, 4*time.Minute, watchBackoff(30*time.Second, 3))
+	assert.Equal(t, watchMaxBackoff, watchBackoff(30*time.Second, 100))
+	assert.Equal(t, time.Hour, watchBackoff(time.Hour, 2))
+}
+
+func TestWatchEventContainer(t *testing.T) {
+	event := func(action string, attrs map[string]string) *docker.APIEvents {
+		return &docker.APIEvents{Type: "container", Action: action, Actor: docker.APIActor{Attributes: attrs}}
+	}
+
+	assert.Equal(t, "test.app", watchEventContainer("test", event("die", map[string]string{"name": "test.app"})))
+	assert.Equal(t, "", watchEventContainer("test", event("die", map[string]string{"name": "other.app"})))
+	assert.Equal(t, "", watchEventContainer("test", event("start", map[string]string{"name": "test.app"})))
+	assert.Equal(t, "test.app", watchEventContainer("test", event("rename", map[string]string{"name": "app", "oldName": "/test.app"})))
+	assert.Equal(t, "", watchEventContainer("test", &docker.APIEvents{Type: "network", Action: "destroy"}))
+
+	// events of docker API < 1.22
+	assert.Equal(t, "", watchEventContainer("test", &docker.APIEvents{Status: "destroy", ID: "123"}))
+}
+
+func TestWatchReconcile(t *testing.T) {
+	image := "quay.io/app:1.0"
+	cfg := &config.Config{
+		Namespace: "test",
+		Containers: map[string]*config.Container{
+			"app": &config.Container{Image: &image},
+		},
+	}
+
+	client := clientMock{}
+	client.On("WatchContainers", "test").Return(nil)
+	client.On("GetContainers").Return(nil)
+	client.On("FetchImages", mock.Anything, mock.Anything).Return(nil)
+	client.On("GetNetworks", "test").Return(nil, nil)
+	client.On("GetVolumes", "test").Return(nil, nil)
+	client.On("RunContainer", mock.Anything).Return(nil)
+
+	compose := &Compose{client: &client, Manifest: cfg}
+
+	stop := make(chan struct{})
+	close(stop)
+
+	// the removed container is created again before watching stops
+	assert.NoError(t, compose.WatchAction(time.Minute, stop))
+	client.AssertExpectations(t)
+
+	client = clientMock{}
+	client.On("WatchContainers", "test").Return(fmt.Errorf("connection refused"))
+	compose = &Compose{client: &client, Manifest: cfg}
+	assert.Error(t, compose.WatchAction(time.Minute, stop))
+}
+
+func TestWatchReconcileNetworks(t *testing.T) {
+	cfg := &config.Config{
+		Namespace: "test",
+		Networks: map[string]*config.Network{
+			"backend": &config.Network{},
+		},
+	}
+	old := &Network{ID: "n1", Name: config.NewContainerName("test", "old"), Config: &config.Network{}}
+
+	// networks are reconciled even if containers have not drifted
+	client := clientMock{}
+	client.On("GetContainers").Return(nil)
+	client.On("FetchImages", mock.Anything, mock.Anything).Return(nil)
+	client.On("GetNetworks", "test").Return([]*Network{old}, nil)
+	client.On("CreateNetwork", GetNetworksFromConfig(cfg)[0]).Return(nil)
+	client.On("RemoveNetwork", old).Return(nil)
+	client.On("GetVolumes", "test").Return(nil, nil)
+
+	compose := &Compose{client: &client, Manifest: cfg}
+
+	assert.NoError(t, compose.reconcile())
+	client.AssertExpectations(t)
+}